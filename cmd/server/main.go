@@ -10,11 +10,20 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/luytbq/personal-notification-service/internal/admin"
 	"github.com/luytbq/personal-notification-service/internal/api"
 	"github.com/luytbq/personal-notification-service/internal/channels"
 	"github.com/luytbq/personal-notification-service/internal/config"
+	"github.com/luytbq/personal-notification-service/internal/dedup"
+	"github.com/luytbq/personal-notification-service/internal/flood"
+	"github.com/luytbq/personal-notification-service/internal/history"
+	"github.com/luytbq/personal-notification-service/internal/logging"
+	"github.com/luytbq/personal-notification-service/internal/notification"
 	"github.com/luytbq/personal-notification-service/internal/queue"
 	"github.com/luytbq/personal-notification-service/internal/ratelimit"
+	"github.com/luytbq/personal-notification-service/internal/silence"
+	"github.com/luytbq/personal-notification-service/internal/stream"
+	"github.com/luytbq/personal-notification-service/internal/tracing"
 )
 
 func main() {
@@ -26,30 +35,128 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Replace the bootstrap logger with the configured one
+	levels := new(logging.Levels)
+	if err := levels.Apply(cfg.Server.LogLevel, cfg.Server.LogLevels); err != nil {
+		logger.Error("invalid log level", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	logs, err := logging.New(cfg.Server.LogFormat, cfg.Server.LogOutput, levels)
+	if err != nil {
+		logger.Error("failed to set up logging", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	defer logs.Close()
+	logger = logs.Logger()
+	slog.SetDefault(logger)
+	apiLogger := logs.For(logging.ComponentAPI)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		logger.Error("failed to set up tracing", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	if cfg.Tracing.Enabled {
+		logger.Info("tracing enabled",
+			slog.String("endpoint", cfg.Tracing.Endpoint),
+			slog.Float64("sample_ratio", cfg.Tracing.SampleRatio),
+		)
+	}
+
 	logger.Info("configuration loaded",
 		slog.Int("port", cfg.Server.Port),
+		slog.String("log_level", cfg.Server.LogLevel),
+		slog.Any("log_levels", cfg.Server.LogLevels),
 		slog.Int("rate_limit_per_minute", cfg.RateLimitPerMinute),
 		slog.Int("worker_concurrency", cfg.Worker.Concurrency),
 		slog.Int("max_retries", cfg.Worker.MaxRetries),
 	)
 
+	for _, k := range cfg.APIKeys {
+		logger.Info("api key configured",
+			slog.String("id", k.ID),
+			slog.String("name", k.Name),
+			slog.String("owner", k.Owner),
+		)
+	}
+
 	registry := channels.NewRegistry()
-	registry.Register(channels.NewTelegramChannel(cfg.Telegram.BotToken, cfg.Telegram.ChatID))
-	// Email channel is scaffolded but not implemented
-	// registry.Register(channels.NewEmailChannel())
 
+	// Runtime-managed keys and targets, persisted in Redis
+	keyring := admin.NewKeyring(cfg.APIKeys)
+	adminStore := admin.NewStore(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB, cfg.Redis.KeyPrefix)
+	defer adminStore.Close()
+
+	adminManager := admin.NewManager(adminStore, keyring, registry, logger)
+	adminManager.SetConfigChannels(configChannels(cfg))
 	for _, wc := range cfg.Webhooks {
-		ch := channels.NewWebhookChannel(wc.Name, wc.URL, wc.Secret)
-		registry.Register(ch)
-		logger.Info("registered webhook channel", slog.String("name", string(ch.Name())))
+		logger.Info("registered webhook channel", slog.String("name", notification.ChannelWebhookPrefix+wc.Name))
+	}
+
+	if err := adminManager.Sync(context.Background()); err != nil {
+		logger.Error("failed to load runtime keys and targets", slog.String("error", err.Error()))
+		os.Exit(1)
 	}
 
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	go adminManager.Watch(watchCtx)
+
 	limiter := ratelimit.NewLimiter(cfg.RateLimitPerMinute)
 
+	initialPolicy, err := api.NewPolicy(cfg, registry)
+	if err != nil {
+		logger.Error("invalid configuration", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	var policy api.PolicyHolder
+	policy.Store(initialPolicy)
+	adminManager.SetLevels(initialPolicy.Levels)
+	logger.Info("routing rules loaded",
+		slog.Int("rules", len(cfg.Routing.Rules)),
+		slog.Any("fallback", cfg.Routing.Fallback),
+	)
+
+	// Notification history; left nil when disabled
+	var historyStore history.Store
+	if cfg.History.Enabled {
+		sqliteStore, err := history.NewSQLiteStore(cfg.History.Path)
+		if err != nil {
+			logger.Error("failed to open notification history", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		defer sqliteStore.Close()
+		historyStore = sqliteStore
+		logger.Info("notification history enabled",
+			slog.String("path", cfg.History.Path),
+			slog.Int("retention_days", cfg.History.RetentionDays),
+		)
+		if cfg.History.RetentionDays > 0 {
+			retention := time.Duration(cfg.History.RetentionDays) * 24 * time.Hour
+			go history.RunPruner(watchCtx, historyStore, retention, logger)
+		}
+	}
+
+	// Live notification events, shared between replicas through Redis.
+	// Streams end when the HTTP server shuts down so they do not hold it open.
+	hub := stream.NewHub(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB, cfg.Redis.KeyPrefix, apiLogger)
+	defer hub.Close()
+	streamCtx, stopStreams := context.WithCancel(context.Background())
+	defer stopStreams()
+	go hub.Run(streamCtx)
+
+	formatters, err := cfg.Formatting.Formatters(initialPolicy.Levels)
+	if err != nil {
+		logger.Error("invalid configuration", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
 	queueNames := queue.NewQueueNames(cfg.Redis.KeyPrefix)
 	logger.Info("queue names configured",
 		slog.String("prefix", cfg.Redis.KeyPrefix),
 		slog.String("notifications_queue", queueNames.Notifications),
+		slog.String("notifications_high_queue", queueNames.NotificationsHigh),
+		slog.String("notifications_low_queue", queueNames.NotificationsLow),
 		slog.String("task_type", queueNames.TaskType),
 	)
 
@@ -58,22 +165,70 @@ func main() {
 		cfg.Redis.Password,
 		cfg.Redis.DB,
 		cfg.Worker.MaxRetries,
-		logger,
+		apiLogger,
 		queueNames,
+		initialPolicy.Groups,
+		historyStore,
+		hub,
 	)
 	defer queueClient.Close()
 
+	silences := silence.NewStore(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB, cfg.Redis.KeyPrefix, logs.For(logging.ComponentWorker))
+	defer silences.Close()
+
 	worker := queue.NewWorker(
 		cfg.Redis.Addr,
 		cfg.Redis.Password,
 		cfg.Redis.DB,
 		cfg.Worker.Concurrency,
 		registry,
-		logger,
+		logs.For(logging.ComponentWorker),
+		logs.For(logging.ComponentChannels),
 		queueNames,
+		historyStore,
+		hub,
+		formatters,
+		queue.DigestOptions{
+			GracePeriod: time.Duration(cfg.Digest.GracePeriodSeconds) * time.Second,
+			MaxDelay:    time.Duration(cfg.Digest.MaxDelaySeconds) * time.Second,
+			MaxSize:     cfg.Digest.MaxSize,
+			MaxRetries:  cfg.Worker.MaxRetries,
+		},
+		silences,
 	)
 
-	router := api.NewRouter(cfg, limiter, queueClient, logger)
+	// Repeat suppression; left nil when disabled
+	var deduper *dedup.Deduper
+	if cfg.Dedup.Enabled {
+		window := time.Duration(cfg.Dedup.WindowSeconds) * time.Second
+		deduper = dedup.NewDeduper(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB, cfg.Redis.KeyPrefix, window, cfg.Dedup.Fields, queueClient, logs.For(logging.ComponentWorker))
+		defer deduper.Close()
+		worker.Handle(deduper.TaskType(), deduper)
+		logger.Info("deduplication enabled",
+			slog.Duration("window", window),
+			slog.Any("fields", cfg.Dedup.Fields),
+		)
+	}
+
+	// Flood protection; left nil when disabled
+	var muter *flood.Muter
+	if cfg.Flood.Enabled {
+		window := time.Duration(cfg.Flood.WindowSeconds) * time.Second
+		cooldown := time.Duration(cfg.Flood.CooldownSeconds) * time.Second
+		muter = flood.NewMuter(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB, cfg.Redis.KeyPrefix, cfg.Flood.Threshold, window, cooldown, notification.Level(cfg.Flood.BypassLevel), cfg.Dedup.Fields, queueClient, logs.For(logging.ComponentWorker))
+		defer muter.Close()
+		worker.Handle(muter.TaskType(), muter)
+		logger.Info("flood protection enabled",
+			slog.Int("threshold", cfg.Flood.Threshold),
+			slog.Duration("window", window),
+			slog.Duration("cooldown", cooldown),
+		)
+	}
+
+	inspector := queue.NewInspector(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB, queueNames)
+	defer inspector.Close()
+
+	router := api.NewRouter(cfg, limiter, queueClient, &policy, keyring, adminManager, levels, historyStore, inspector, registry, hub, deduper, muter, silences, apiLogger)
 
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.Server.Port),
@@ -82,6 +237,7 @@ func main() {
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
+	server.RegisterOnShutdown(stopStreams)
 
 	go func() {
 		logger.Info("starting worker", slog.Int("concurrency", cfg.Worker.Concurrency))
@@ -98,9 +254,28 @@ func main() {
 		}
 	}()
 
+	reload := &reloader{
+		current:  cfg,
+		keyring:  keyring,
+		limiter:  limiter,
+		manager:  adminManager,
+		client:   queueClient,
+		worker:   worker,
+		policy:   &policy,
+		registry: registry,
+		levels:   levels,
+		logger:   logger,
+	}
+	if cfg.Server.WatchConfig {
+		go reload.WatchFile(watchCtx, config.Path())
+	}
+
+	// SIGHUP reloads the config; SIGINT/SIGTERM shut down
 	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := <-quit; sig == syscall.SIGHUP; sig = <-quit {
+		reload.Reload("SIGHUP")
+	}
 
 	logger.Info("shutting down...")
 
@@ -115,9 +290,14 @@ func main() {
 
 	worker.Shutdown()
 	logger.Info("worker stopped")
+
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Error("failed to flush traces", slog.String("error", err.Error()))
+	}
 	logger.Info("shutdown complete")
 }
 
+// setupLogger creates the bootstrap logger used until the config is loaded
 func setupLogger() *slog.Logger {
 	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo})
 	logger := slog.New(handler)
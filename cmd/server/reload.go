@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/luytbq/personal-notification-service/internal/admin"
+	"github.com/luytbq/personal-notification-service/internal/api"
+	"github.com/luytbq/personal-notification-service/internal/channels"
+	"github.com/luytbq/personal-notification-service/internal/config"
+	"github.com/luytbq/personal-notification-service/internal/logging"
+	"github.com/luytbq/personal-notification-service/internal/queue"
+	"github.com/luytbq/personal-notification-service/internal/ratelimit"
+)
+
+// reloadDebounce groups the several write events editors emit for one save
+const reloadDebounce = 500 * time.Millisecond
+
+// reloader re-reads the config file and swaps the reloadable parts of the
+// running service. A config that fails validation is rejected as a whole and
+// the current one stays in effect.
+type reloader struct {
+	mu       sync.Mutex
+	current  *config.Config
+	keyring  *admin.Keyring
+	limiter  *ratelimit.Limiter
+	manager  *admin.Manager
+	client   *queue.Client
+	worker   *queue.Worker
+	policy   *api.PolicyHolder
+	registry *channels.Registry
+	levels   *logging.Levels
+	logger   *slog.Logger
+}
+
+// Reload loads, validates and applies the config file
+func (r *reloader) Reload(trigger string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	logger := r.logger.With(slog.String("trigger", trigger))
+
+	cfg, err := config.Load()
+	if err != nil {
+		logger.Error("config reload failed, keeping current configuration", slog.String("error", err.Error()))
+		return
+	}
+
+	policy, err := api.NewPolicy(cfg, r.registry)
+	if err != nil {
+		logger.Error("config reload failed, keeping current configuration", slog.String("error", err.Error()))
+		return
+	}
+
+	formatters, err := cfg.Formatting.Formatters(policy.Levels)
+	if err != nil {
+		logger.Error("config reload failed, keeping current configuration", slog.String("error", err.Error()))
+		return
+	}
+
+	changes := config.Diff(r.current, cfg)
+	if len(changes) == 0 {
+		logger.Info("config reloaded, no changes")
+		return
+	}
+
+	// Channels first, so the new policy never refers to a missing channel
+	r.manager.SetConfigChannels(configChannels(cfg))
+	r.manager.SetLevels(policy.Levels)
+	r.keyring.SetStatic(cfg.APIKeys)
+	r.limiter.SetRate(cfg.RateLimitPerMinute)
+	r.client.SetGroups(policy.Groups)
+	r.policy.Store(policy)
+	r.worker.SetFormatters(formatters)
+	if cfg.Server.LogLevel != r.current.Server.LogLevel || !reflect.DeepEqual(cfg.Server.LogLevels, r.current.Server.LogLevels) {
+		// Validated by config.Load; this also drops runtime overrides
+		_ = r.levels.Apply(cfg.Server.LogLevel, cfg.Server.LogLevels)
+	}
+	r.current = cfg
+
+	logger.Info("config reloaded", slog.Any("changes", changes))
+}
+
+// WatchFile reloads whenever the config file changes, until ctx is done.
+// The directory is watched rather than the file so that editors and
+// config management tools that replace the file are picked up too.
+func (r *reloader) WatchFile(ctx context.Context, path string) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		r.logger.Error("failed to watch config file", slog.String("error", err.Error()))
+		return
+	}
+	defer watcher.Close()
+
+	path = filepath.Clean(path)
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		r.logger.Error("failed to watch config file", slog.String("path", path), slog.String("error", err.Error()))
+		return
+	}
+	r.logger.Info("watching config file for changes", slog.String("path", path))
+
+	var timer *time.Timer
+	for {
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != path || !event.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+				continue
+			}
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.AfterFunc(reloadDebounce, func() { r.Reload("file change") })
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			r.logger.Warn("config file watcher error", slog.String("error", err.Error()))
+		}
+	}
+}
+
+// configChannels builds the delivery channels defined in the config file
+func configChannels(cfg *config.Config) []channels.Channel {
+	chs := []channels.Channel{
+		channels.NewTelegramChannel(cfg.Telegram.BotToken, cfg.Telegram.ChatID),
+		// Email channel is scaffolded but not implemented
+		// channels.NewEmailChannel(),
+	}
+	for _, wc := range cfg.Webhooks {
+		chs = append(chs, channels.NewWebhookChannel(wc.Name, wc.URL, wc.Secret))
+	}
+	return chs
+}
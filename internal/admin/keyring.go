@@ -0,0 +1,106 @@
+package admin
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/luytbq/personal-notification-service/internal/config"
+)
+
+// Keyring resolves raw API keys to identities. It combines the keys from the
+// config file with keys managed at runtime and is safe for concurrent use.
+type Keyring struct {
+	mu      sync.RWMutex
+	static  map[string]*config.APIKey // by key hash
+	dynamic map[string]*config.APIKey // by key hash
+}
+
+// NewKeyring creates a Keyring holding the configured keys.
+// Keys must already be hashed, as done by config.Load.
+func NewKeyring(static []config.APIKey) *Keyring {
+	k := &Keyring{}
+	k.SetStatic(static)
+	k.SetDynamic(nil)
+	return k
+}
+
+// SetStatic replaces the keys that come from the config file
+func (k *Keyring) SetStatic(keys []config.APIKey) {
+	m := indexKeys(keys)
+	k.mu.Lock()
+	k.static = m
+	k.mu.Unlock()
+}
+
+// SetDynamic replaces the keys managed at runtime
+func (k *Keyring) SetDynamic(keys []config.APIKey) {
+	m := indexKeys(keys)
+	k.mu.Lock()
+	k.dynamic = m
+	k.mu.Unlock()
+}
+
+// LookupAPIKey returns the identity for the provided raw API key.
+// Config keys take precedence over runtime keys.
+func (k *Keyring) LookupAPIKey(key string) (*config.APIKey, bool) {
+	hash := config.HashAPIKey(key)
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if id, ok := k.static[hash]; ok {
+		return id, true
+	}
+	id, ok := k.dynamic[hash]
+	return id, ok
+}
+
+// IsStatic reports whether the ID belongs to a key from the config file
+func (k *Keyring) IsStatic(id string) bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	for _, key := range k.static {
+		if key.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyInfo describes a key for listing. Its KeyHash is left empty, so that
+// listings never expose the hash.
+type KeyInfo struct {
+	config.APIKey
+	Source string `json:"source"` // "config" or "runtime"
+}
+
+// List returns all keys sorted by ID
+func (k *Keyring) List() []KeyInfo {
+	k.mu.RLock()
+	keys := make([]KeyInfo, 0, len(k.static)+len(k.dynamic))
+	for _, key := range k.static {
+		keys = append(keys, newKeyInfo(*key, "config"))
+	}
+	for _, key := range k.dynamic {
+		keys = append(keys, newKeyInfo(*key, "runtime"))
+	}
+	k.mu.RUnlock()
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
+
+func newKeyInfo(key config.APIKey, source string) KeyInfo {
+	key.KeyHash = ""
+	return KeyInfo{APIKey: key, Source: source}
+}
+
+func indexKeys(keys []config.APIKey) map[string]*config.APIKey {
+	m := make(map[string]*config.APIKey, len(keys))
+	for i := range keys {
+		key := keys[i]
+		m[key.KeyHash] = &key
+	}
+	return m
+}
@@ -0,0 +1,322 @@
+package admin
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/luytbq/personal-notification-service/internal/channels"
+	"github.com/luytbq/personal-notification-service/internal/config"
+	"github.com/luytbq/personal-notification-service/internal/notification"
+)
+
+var (
+	ErrNotFound = errors.New("not found")
+	ErrConflict = errors.New("already exists")
+	ErrReadOnly = errors.New("defined in the config file and cannot be changed at runtime")
+	ErrStorage  = errors.New("storage error")
+)
+
+// KeySpec describes an API key to create
+type KeySpec struct {
+	ID        string
+	Name      string
+	Owner     string
+	ExpiresAt time.Time
+	Scopes    config.APIKeyScopes
+}
+
+// Manager applies runtime changes to API keys and channel targets. Changes are
+// persisted in Redis and broadcast so that every replica updates its keyring
+// and channel registry live.
+type Manager struct {
+	store    *Store
+	keyring  *Keyring
+	registry *channels.Registry
+	logger   *slog.Logger
+
+	mu      sync.Mutex
+	static  map[notification.Channel]bool // channels from the config file
+	targets map[notification.Channel]Target
+	levels  *notification.Levels // allowed in key scopes
+}
+
+// NewManager creates a Manager. Channels already in the registry are treated
+// as config-defined and cannot be changed through the manager.
+func NewManager(store *Store, keyring *Keyring, registry *channels.Registry, logger *slog.Logger) *Manager {
+	static := make(map[notification.Channel]bool)
+	for _, name := range registry.Names() {
+		static[name] = true
+	}
+
+	return &Manager{
+		store:    store,
+		keyring:  keyring,
+		registry: registry,
+		logger:   logger,
+		static:   static,
+		targets:  make(map[notification.Channel]Target),
+		levels:   notification.DefaultLevels,
+	}
+}
+
+// SetLevels replaces the levels that key scopes may name, e.g. after a
+// config reload
+func (m *Manager) SetLevels(levels *notification.Levels) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.levels = levels
+}
+
+// SetConfigChannels replaces the config-defined channels in the registry,
+// e.g. after a config reload. Config channels take precedence over runtime
+// targets with the same name.
+func (m *Manager) SetConfigChannels(chs []channels.Channel) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	static := make(map[notification.Channel]bool, len(chs))
+	for _, ch := range chs {
+		static[ch.Name()] = true
+		m.registry.Register(ch)
+		delete(m.targets, ch.Name())
+	}
+
+	for name := range m.static {
+		if !static[name] {
+			m.registry.Unregister(name)
+		}
+	}
+	m.static = static
+}
+
+// Sync loads keys and targets from Redis and applies them
+func (m *Manager) Sync(ctx context.Context) error {
+	keys, err := m.store.ListKeys(ctx)
+	if err != nil {
+		return err
+	}
+	targets, err := m.store.ListTargets(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.keyring.SetDynamic(keys)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	desired := make(map[notification.Channel]Target, len(targets))
+	for _, t := range targets {
+		if m.static[t.Channel] {
+			m.logger.Warn("ignoring stored target shadowing a config channel",
+				slog.String("channel", string(t.Channel)),
+			)
+			continue
+		}
+		if err := t.Validate(); err != nil {
+			m.logger.Warn("ignoring invalid stored target",
+				slog.String("channel", string(t.Channel)),
+				slog.String("error", err.Error()),
+			)
+			continue
+		}
+		desired[t.Channel] = t
+	}
+
+	for name := range m.targets {
+		if _, ok := desired[name]; !ok {
+			m.registry.Unregister(name)
+		}
+	}
+	for name, t := range desired {
+		if current, ok := m.targets[name]; !ok || current != t {
+			m.registry.Register(t.Build())
+		}
+	}
+	m.targets = desired
+
+	m.logger.Info("runtime keys and targets synced",
+		slog.Int("api_keys", len(keys)),
+		slog.Int("targets", len(desired)),
+	)
+	return nil
+}
+
+// Watch re-syncs whenever another replica publishes a change, until ctx is done
+func (m *Manager) Watch(ctx context.Context) {
+	sub := m.store.SubscribeChanges(ctx)
+	defer sub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-sub.Channel():
+			if !ok {
+				return
+			}
+			if err := m.Sync(ctx); err != nil {
+				m.logger.Error("failed to sync runtime keys and targets",
+					slog.String("error", err.Error()),
+				)
+			}
+		}
+	}
+}
+
+// CreateKey creates an API key and returns its raw secret, which is not stored
+func (m *Manager) CreateKey(ctx context.Context, spec KeySpec) (string, config.APIKey, error) {
+	m.mu.Lock()
+	levels := m.levels
+	m.mu.Unlock()
+	if err := spec.Scopes.Validate(levels); err != nil {
+		return "", config.APIKey{}, err
+	}
+
+	raw, err := generateKey()
+	if err != nil {
+		return "", config.APIKey{}, err
+	}
+
+	key := config.APIKey{
+		ID:        spec.ID,
+		Name:      spec.Name,
+		Owner:     spec.Owner,
+		KeyHash:   config.HashAPIKey(raw),
+		ExpiresAt: spec.ExpiresAt,
+		Scopes:    spec.Scopes,
+	}
+	if key.ID == "" {
+		key.ID = config.DeriveAPIKeyID(key.KeyHash)
+	}
+	if key.Name == "" {
+		key.Name = key.ID
+	}
+
+	if m.keyring.IsStatic(key.ID) {
+		return "", config.APIKey{}, fmt.Errorf("api key %q: %w", key.ID, ErrConflict)
+	}
+
+	created, err := m.store.CreateKey(ctx, key)
+	if err != nil {
+		return "", config.APIKey{}, storageErr(err)
+	}
+	if !created {
+		return "", config.APIKey{}, fmt.Errorf("api key %q: %w", key.ID, ErrConflict)
+	}
+
+	m.changed(ctx)
+	m.logger.Info("api key created", slog.String("id", key.ID), slog.String("owner", key.Owner))
+	return raw, key, nil
+}
+
+// RevokeKey deletes a runtime API key
+func (m *Manager) RevokeKey(ctx context.Context, id string) error {
+	if m.keyring.IsStatic(id) {
+		return fmt.Errorf("api key %q is %w", id, ErrReadOnly)
+	}
+
+	deleted, err := m.store.DeleteKey(ctx, id)
+	if err != nil {
+		return storageErr(err)
+	}
+	if !deleted {
+		return fmt.Errorf("api key %q: %w", id, ErrNotFound)
+	}
+
+	m.changed(ctx)
+	m.logger.Info("api key revoked", slog.String("id", id))
+	return nil
+}
+
+// Keys lists all API keys
+func (m *Manager) Keys() []KeyInfo {
+	return m.keyring.List()
+}
+
+// PutTarget creates or updates a runtime channel target
+func (m *Manager) PutTarget(ctx context.Context, t Target) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	if m.isStatic(t.Channel) {
+		return fmt.Errorf("channel %s is %w", t.Channel, ErrReadOnly)
+	}
+
+	if err := m.store.SaveTarget(ctx, t); err != nil {
+		return storageErr(err)
+	}
+
+	m.changed(ctx)
+	m.logger.Info("channel target saved", slog.String("channel", string(t.Channel)))
+	return nil
+}
+
+// DeleteTarget removes a runtime channel target
+func (m *Manager) DeleteTarget(ctx context.Context, name notification.Channel) error {
+	if m.isStatic(name) {
+		return fmt.Errorf("channel %s is %w", name, ErrReadOnly)
+	}
+
+	deleted, err := m.store.DeleteTarget(ctx, name)
+	if err != nil {
+		return storageErr(err)
+	}
+	if !deleted {
+		return fmt.Errorf("channel %s: %w", name, ErrNotFound)
+	}
+
+	m.changed(ctx)
+	m.logger.Info("channel target deleted", slog.String("channel", string(name)))
+	return nil
+}
+
+// Targets lists runtime channel targets with secrets redacted
+func (m *Manager) Targets() []Target {
+	m.mu.Lock()
+	targets := make([]Target, 0, len(m.targets))
+	for _, t := range m.targets {
+		targets = append(targets, t.Redacted())
+	}
+	m.mu.Unlock()
+
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Channel < targets[j].Channel })
+	return targets
+}
+
+func (m *Manager) isStatic(name notification.Channel) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.static[name]
+}
+
+// changed applies a change locally and tells the other replicas about it
+func (m *Manager) changed(ctx context.Context) {
+	if err := m.Sync(ctx); err != nil {
+		m.logger.Error("failed to sync runtime keys and targets", slog.String("error", err.Error()))
+	}
+	if err := m.store.PublishChange(ctx); err != nil {
+		m.logger.Error("failed to publish admin change", slog.String("error", err.Error()))
+	}
+}
+
+// storageErr marks an error as a storage failure rather than a bad request
+func storageErr(err error) error {
+	return fmt.Errorf("%w: %w", ErrStorage, err)
+}
+
+// generateKey returns a random 256-bit key, hex encoded
+func generateKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
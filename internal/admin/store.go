@@ -0,0 +1,131 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/luytbq/personal-notification-service/internal/config"
+	"github.com/luytbq/personal-notification-service/internal/notification"
+	"github.com/redis/go-redis/v9"
+)
+
+// Store persists runtime-managed API keys and channel targets in Redis.
+// Keys and targets are kept in two hashes, indexed by key ID and channel name.
+type Store struct {
+	rdb        *redis.Client
+	keysKey    string
+	targetsKey string
+	eventsKey  string
+}
+
+// NewStore creates a new Redis-backed store using the given key prefix
+func NewStore(redisAddr, redisPassword string, redisDB int, prefix string) *Store {
+	return &Store{
+		rdb: redis.NewClient(&redis.Options{
+			Addr:     redisAddr,
+			Password: redisPassword,
+			DB:       redisDB,
+		}),
+		keysKey:    fmt.Sprintf("%s:admin:keys", prefix),
+		targetsKey: fmt.Sprintf("%s:admin:targets", prefix),
+		eventsKey:  fmt.Sprintf("%s:admin:events", prefix),
+	}
+}
+
+// Close closes the Redis connection
+func (s *Store) Close() error {
+	return s.rdb.Close()
+}
+
+// ListKeys returns all stored API keys
+func (s *Store) ListKeys(ctx context.Context) ([]config.APIKey, error) {
+	values, err := s.rdb.HGetAll(ctx, s.keysKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+
+	keys := make([]config.APIKey, 0, len(values))
+	for id, raw := range values {
+		var k config.APIKey
+		if err := json.Unmarshal([]byte(raw), &k); err != nil {
+			return nil, fmt.Errorf("failed to decode api key %q: %w", id, err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// CreateKey stores a new API key. Returns false if the ID is already taken.
+func (s *Store) CreateKey(ctx context.Context, k config.APIKey) (bool, error) {
+	data, err := json.Marshal(k)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode api key: %w", err)
+	}
+
+	created, err := s.rdb.HSetNX(ctx, s.keysKey, k.ID, data).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to store api key: %w", err)
+	}
+	return created, nil
+}
+
+// DeleteKey removes an API key. Returns false if it did not exist.
+func (s *Store) DeleteKey(ctx context.Context, id string) (bool, error) {
+	n, err := s.rdb.HDel(ctx, s.keysKey, id).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete api key: %w", err)
+	}
+	return n > 0, nil
+}
+
+// ListTargets returns all stored channel targets
+func (s *Store) ListTargets(ctx context.Context) ([]Target, error) {
+	values, err := s.rdb.HGetAll(ctx, s.targetsKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list targets: %w", err)
+	}
+
+	targets := make([]Target, 0, len(values))
+	for name, raw := range values {
+		var t Target
+		if err := json.Unmarshal([]byte(raw), &t); err != nil {
+			return nil, fmt.Errorf("failed to decode target %q: %w", name, err)
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+// SaveTarget creates or replaces a channel target
+func (s *Store) SaveTarget(ctx context.Context, t Target) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("failed to encode target: %w", err)
+	}
+
+	if err := s.rdb.HSet(ctx, s.targetsKey, string(t.Channel), data).Err(); err != nil {
+		return fmt.Errorf("failed to store target: %w", err)
+	}
+	return nil
+}
+
+// DeleteTarget removes a channel target. Returns false if it did not exist.
+func (s *Store) DeleteTarget(ctx context.Context, name notification.Channel) (bool, error) {
+	n, err := s.rdb.HDel(ctx, s.targetsKey, string(name)).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete target: %w", err)
+	}
+	return n > 0, nil
+}
+
+// PublishChange notifies all replicas that keys or targets changed
+func (s *Store) PublishChange(ctx context.Context) error {
+	return s.rdb.Publish(ctx, s.eventsKey, "changed").Err()
+}
+
+// SubscribeChanges returns a subscription to change notifications.
+// The caller must close it.
+func (s *Store) SubscribeChanges(ctx context.Context) *redis.PubSub {
+	return s.rdb.Subscribe(ctx, s.eventsKey)
+}
@@ -0,0 +1,76 @@
+package admin
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/luytbq/personal-notification-service/internal/channels"
+	"github.com/luytbq/personal-notification-service/internal/notification"
+)
+
+// Target is a runtime-managed channel target: either a webhook
+// ("webhook:<name>") or a named Telegram chat ("telegram:<name>")
+type Target struct {
+	Channel  notification.Channel `json:"channel"`
+	URL      string               `json:"url,omitempty"`
+	Secret   string               `json:"secret,omitempty"`
+	BotToken string               `json:"bot_token,omitempty"`
+	ChatID   string               `json:"chat_id,omitempty"`
+}
+
+// IsWebhook reports whether the target is a webhook
+func (t Target) IsWebhook() bool {
+	return strings.HasPrefix(string(t.Channel), notification.ChannelWebhookPrefix)
+}
+
+// IsTelegram reports whether the target is a named Telegram chat
+func (t Target) IsTelegram() bool {
+	return strings.HasPrefix(string(t.Channel), notification.ChannelTelegramPrefix)
+}
+
+// Validate checks that the target has the fields its kind requires
+func (t Target) Validate() error {
+	if !t.Channel.IsValid() {
+		return fmt.Errorf("invalid channel %q", t.Channel)
+	}
+
+	switch {
+	case t.IsWebhook():
+		u, err := url.Parse(t.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("url must be an absolute http(s) URL")
+		}
+	case t.IsTelegram():
+		if t.BotToken == "" {
+			return fmt.Errorf("bot_token is required")
+		}
+		if t.ChatID == "" {
+			return fmt.Errorf("chat_id is required")
+		}
+	default:
+		return fmt.Errorf("channel must be webhook:<name> or telegram:<name>")
+	}
+	return nil
+}
+
+// Build creates the delivery channel for the target
+func (t Target) Build() channels.Channel {
+	if t.IsTelegram() {
+		name := strings.TrimPrefix(string(t.Channel), notification.ChannelTelegramPrefix)
+		return channels.NewNamedTelegramChannel(name, t.BotToken, t.ChatID)
+	}
+	name := strings.TrimPrefix(string(t.Channel), notification.ChannelWebhookPrefix)
+	return channels.NewWebhookChannel(name, t.URL, t.Secret)
+}
+
+// Redacted returns a copy of the target with secrets masked for display
+func (t Target) Redacted() Target {
+	if t.Secret != "" {
+		t.Secret = "****"
+	}
+	if t.BotToken != "" {
+		t.BotToken = "****"
+	}
+	return t
+}
@@ -0,0 +1,236 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/luytbq/personal-notification-service/internal/admin"
+	"github.com/luytbq/personal-notification-service/internal/config"
+	"github.com/luytbq/personal-notification-service/internal/logging"
+	"github.com/luytbq/personal-notification-service/internal/notification"
+)
+
+// AdminHandler handles the /admin API for runtime key and channel management
+type AdminHandler struct {
+	manager *admin.Manager
+	levels  *logging.Levels
+	logger  *slog.Logger
+	maxBody int64
+}
+
+// NewAdminHandler creates a new AdminHandler
+func NewAdminHandler(manager *admin.Manager, levels *logging.Levels, maxBody int64, logger *slog.Logger) *AdminHandler {
+	return &AdminHandler{
+		manager: manager,
+		levels:  levels,
+		logger:  logger,
+		maxBody: maxBody,
+	}
+}
+
+// createKeyRequest is the body of POST /admin/keys
+type createKeyRequest struct {
+	ID        string              `json:"id"`
+	Name      string              `json:"name"`
+	Owner     string              `json:"owner"`
+	ExpiresAt *time.Time          `json:"expires_at,omitempty"`
+	Scopes    config.APIKeyScopes `json:"scopes"`
+}
+
+// keyResponse describes an API key without its hash
+type keyResponse struct {
+	ID        string              `json:"id"`
+	Name      string              `json:"name"`
+	Owner     string              `json:"owner,omitempty"`
+	ExpiresAt *time.Time          `json:"expires_at,omitempty"`
+	Scopes    config.APIKeyScopes `json:"scopes"`
+	Source    string              `json:"source,omitempty"`
+	Key       string              `json:"key,omitempty"` // only returned on creation
+}
+
+// targetRequest is the body of PUT /admin/targets/{channel}
+type targetRequest struct {
+	URL      string `json:"url,omitempty"`
+	Secret   string `json:"secret,omitempty"`
+	BotToken string `json:"bot_token,omitempty"`
+	ChatID   string `json:"chat_id,omitempty"`
+}
+
+// logLevelRequest is the body of PUT /admin/log-level. An empty component
+// sets the default level; an empty level resets a component to the default.
+type logLevelRequest struct {
+	Level     string `json:"level"`
+	Component string `json:"component,omitempty"`
+}
+
+// logLevelResponse describes the current log levels
+type logLevelResponse struct {
+	Level      string            `json:"level"`
+	Components map[string]string `json:"components"`
+}
+
+// HandleListKeys handles GET /admin/keys
+func (h *AdminHandler) HandleListKeys(w http.ResponseWriter, r *http.Request) {
+	keys := h.manager.Keys()
+	resp := make([]keyResponse, 0, len(keys))
+	for _, k := range keys {
+		kr := newKeyResponse(k.APIKey)
+		kr.Source = k.Source
+		resp = append(resp, kr)
+	}
+	WriteJSON(w, http.StatusOK, map[string]interface{}{"keys": resp})
+}
+
+// HandleCreateKey handles POST /admin/keys
+func (h *AdminHandler) HandleCreateKey(w http.ResponseWriter, r *http.Request) {
+	var req createKeyRequest
+	if status, err := decodeJSONBody(w, r, h.maxBody, &req); err != nil {
+		WriteError(w, status, err.Error())
+		return
+	}
+
+	spec := admin.KeySpec{
+		ID:     req.ID,
+		Name:   req.Name,
+		Owner:  req.Owner,
+		Scopes: req.Scopes,
+	}
+	if req.ExpiresAt != nil {
+		spec.ExpiresAt = *req.ExpiresAt
+	}
+
+	raw, key, err := h.manager.CreateKey(r.Context(), spec)
+	if err != nil {
+		h.writeAdminError(w, "failed to create api key", err)
+		return
+	}
+
+	resp := newKeyResponse(key)
+	resp.Source = "runtime"
+	resp.Key = raw
+	WriteJSON(w, http.StatusCreated, resp)
+}
+
+// HandleRevokeKey handles DELETE /admin/keys/{id}
+func (h *AdminHandler) HandleRevokeKey(w http.ResponseWriter, r *http.Request) {
+	if err := h.manager.RevokeKey(r.Context(), chi.URLParam(r, "id")); err != nil {
+		h.writeAdminError(w, "failed to revoke api key", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleListTargets handles GET /admin/targets
+func (h *AdminHandler) HandleListTargets(w http.ResponseWriter, r *http.Request) {
+	WriteJSON(w, http.StatusOK, map[string]interface{}{"targets": h.manager.Targets()})
+}
+
+// HandlePutTarget handles PUT /admin/targets/{channel}
+func (h *AdminHandler) HandlePutTarget(w http.ResponseWriter, r *http.Request) {
+	var req targetRequest
+	if status, err := decodeJSONBody(w, r, h.maxBody, &req); err != nil {
+		WriteError(w, status, err.Error())
+		return
+	}
+
+	t := admin.Target{
+		Channel:  notification.Channel(chi.URLParam(r, "channel")),
+		URL:      req.URL,
+		Secret:   req.Secret,
+		BotToken: req.BotToken,
+		ChatID:   req.ChatID,
+	}
+	if err := h.manager.PutTarget(r.Context(), t); err != nil {
+		h.writeAdminError(w, "failed to save target", err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, t.Redacted())
+}
+
+// HandleDeleteTarget handles DELETE /admin/targets/{channel}
+func (h *AdminHandler) HandleDeleteTarget(w http.ResponseWriter, r *http.Request) {
+	name := notification.Channel(chi.URLParam(r, "channel"))
+	if err := h.manager.DeleteTarget(r.Context(), name); err != nil {
+		h.writeAdminError(w, "failed to delete target", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleGetLogLevel handles GET /admin/log-level
+func (h *AdminHandler) HandleGetLogLevel(w http.ResponseWriter, r *http.Request) {
+	WriteJSON(w, http.StatusOK, h.logLevels())
+}
+
+// HandleSetLogLevel handles PUT /admin/log-level. The change is not persisted;
+// it lasts until a restart or a reload that changes the configured levels.
+func (h *AdminHandler) HandleSetLogLevel(w http.ResponseWriter, r *http.Request) {
+	var req logLevelRequest
+	if status, err := decodeJSONBody(w, r, h.maxBody, &req); err != nil {
+		WriteError(w, status, err.Error())
+		return
+	}
+
+	if req.Level == "" {
+		if req.Component == "" || !logging.IsComponent(req.Component) {
+			WriteError(w, http.StatusBadRequest, "level is required")
+			return
+		}
+		h.levels.ResetLevel(req.Component)
+	} else {
+		level, err := logging.ParseLevel(req.Level)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := h.levels.SetLevel(req.Component, level); err != nil {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	resp := h.logLevels()
+	h.logger.Info("log level changed",
+		slog.String("api_key_id", GetAPIKey(r.Context()).ID),
+		slog.String("level", resp.Level),
+		slog.Any("components", resp.Components),
+	)
+	WriteJSON(w, http.StatusOK, resp)
+}
+
+func (h *AdminHandler) logLevels() logLevelResponse {
+	level, components := h.levels.Snapshot()
+	return logLevelResponse{Level: level, Components: components}
+}
+
+// writeAdminError maps manager errors to HTTP statuses
+func (h *AdminHandler) writeAdminError(w http.ResponseWriter, msg string, err error) {
+	switch {
+	case errors.Is(err, admin.ErrNotFound):
+		WriteError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, admin.ErrConflict), errors.Is(err, admin.ErrReadOnly):
+		WriteError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, admin.ErrStorage):
+		h.logger.Error(msg, slog.String("error", err.Error()))
+		WriteError(w, http.StatusInternalServerError, msg)
+	default:
+		WriteError(w, http.StatusBadRequest, err.Error())
+	}
+}
+
+func newKeyResponse(k config.APIKey) keyResponse {
+	resp := keyResponse{
+		ID:     k.ID,
+		Name:   k.Name,
+		Owner:  k.Owner,
+		Scopes: k.Scopes,
+	}
+	if !k.ExpiresAt.IsZero() {
+		expires := k.ExpiresAt
+		resp.ExpiresAt = &expires
+	}
+	return resp
+}
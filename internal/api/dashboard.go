@@ -0,0 +1,104 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/luytbq/personal-notification-service/internal/channels"
+	"github.com/luytbq/personal-notification-service/internal/queue"
+	"github.com/luytbq/personal-notification-service/internal/ratelimit"
+)
+
+// defaultDeadLetterLimit is how many dead letters per queue are listed by default
+const defaultDeadLetterLimit = 50
+
+// DashboardHandler serves the operational state shown on the dashboard:
+// queue depths, dead letters, channel health and rate limit usage
+type DashboardHandler struct {
+	inspector *queue.Inspector
+	registry  *channels.Registry
+	limiter   *ratelimit.Limiter
+	logger    *slog.Logger
+}
+
+// NewDashboardHandler creates a new DashboardHandler
+func NewDashboardHandler(inspector *queue.Inspector, registry *channels.Registry, limiter *ratelimit.Limiter, logger *slog.Logger) *DashboardHandler {
+	return &DashboardHandler{
+		inspector: inspector,
+		registry:  registry,
+		limiter:   limiter,
+		logger:    logger,
+	}
+}
+
+// HandleQueues handles GET /admin/queues
+func (h *DashboardHandler) HandleQueues(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.inspector.Queues()
+	if err != nil {
+		h.logger.Error("failed to inspect queues", slog.String("error", err.Error()))
+		WriteError(w, http.StatusInternalServerError, "failed to inspect queues")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]interface{}{"queues": stats})
+}
+
+// HandleDeadLetters handles GET /admin/dead-letters
+func (h *DashboardHandler) HandleDeadLetters(w http.ResponseWriter, r *http.Request) {
+	limit := defaultDeadLetterLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 1000 {
+			WriteError(w, http.StatusBadRequest, "invalid limit: must be between 1 and 1000")
+			return
+		}
+		limit = n
+	}
+
+	letters, err := h.inspector.DeadLetters(limit)
+	if err != nil {
+		h.logger.Error("failed to list dead letters", slog.String("error", err.Error()))
+		WriteError(w, http.StatusInternalServerError, "failed to list dead letters")
+		return
+	}
+	if letters == nil {
+		letters = []queue.DeadLetter{}
+	}
+	WriteJSON(w, http.StatusOK, map[string]interface{}{"dead_letters": letters})
+}
+
+// HandleRetryDeadLetter handles POST /admin/dead-letters/{id}/retry
+func (h *DashboardHandler) HandleRetryDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := h.inspector.RetryDeadLetter(id); err != nil {
+		if errors.Is(err, queue.ErrTaskNotFound) {
+			WriteError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		h.logger.Error("failed to retry dead letter",
+			slog.String("notification_id", id),
+			slog.String("error", err.Error()),
+		)
+		WriteError(w, http.StatusInternalServerError, "failed to retry dead letter")
+		return
+	}
+
+	h.logger.Info("dead letter requeued",
+		slog.String("request_id", GetRequestID(r.Context())),
+		slog.String("api_key_id", GetAPIKey(r.Context()).ID),
+		slog.String("notification_id", id),
+	)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleChannelHealth handles GET /admin/channels
+func (h *DashboardHandler) HandleChannelHealth(w http.ResponseWriter, r *http.Request) {
+	WriteJSON(w, http.StatusOK, map[string]interface{}{"channels": h.registry.Health()})
+}
+
+// HandleRateLimits handles GET /admin/rate-limits
+func (h *DashboardHandler) HandleRateLimits(w http.ResponseWriter, r *http.Request) {
+	WriteJSON(w, http.StatusOK, map[string]interface{}{"rate_limits": h.limiter.Usage()})
+}
@@ -1,32 +1,56 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/luytbq/personal-notification-service/internal/dedup"
+	"github.com/luytbq/personal-notification-service/internal/flood"
 	"github.com/luytbq/personal-notification-service/internal/notification"
 	"github.com/luytbq/personal-notification-service/internal/queue"
 	"github.com/luytbq/personal-notification-service/internal/ratelimit"
+	"github.com/luytbq/personal-notification-service/internal/silence"
+	"github.com/luytbq/personal-notification-service/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = tracing.Tracer("api")
+
 // Handler handles HTTP requests
 type Handler struct {
-	validator *notification.Validator
-	limiter   *ratelimit.Limiter
-	client    *queue.Client
-	logger    *slog.Logger
+	policy   *PolicyHolder
+	limiter  *ratelimit.Limiter
+	client   *queue.Client
+	dedup    *dedup.Deduper // nil when deduplication is disabled
+	flood    *flood.Muter   // nil when flood protection is disabled
+	silences *silence.Store // nil when silences are not checked
+	logger   *slog.Logger
+	maxBody  int64
 }
 
 // NewHandler creates a new Handler
-func NewHandler(validator *notification.Validator, limiter *ratelimit.Limiter, client *queue.Client, logger *slog.Logger) *Handler {
+// maxBody: maximum accepted request body size in bytes
+// deduper, muter and silences may be nil.
+func NewHandler(policy *PolicyHolder, limiter *ratelimit.Limiter, client *queue.Client, deduper *dedup.Deduper, muter *flood.Muter, silences *silence.Store, maxBody int64, logger *slog.Logger) *Handler {
 	return &Handler{
-		validator: validator,
-		limiter:   limiter,
-		client:    client,
-		logger:    logger,
+		policy:   policy,
+		limiter:  limiter,
+		client:   client,
+		dedup:    deduper,
+		flood:    muter,
+		silences: silences,
+		logger:   logger,
+		maxBody:  maxBody,
 	}
 }
 
@@ -37,69 +61,327 @@ func (h *Handler) HandleNotify(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Every log line for this request carries its ID
+	requestID := GetRequestID(r.Context())
+	logger := h.logger.With(slog.String("request_id", requestID))
+
 	// Get API key from context
 	apiKey := GetAPIKey(r.Context())
-	if apiKey == "" {
+	if apiKey == nil {
 		WriteError(w, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
 	// Parse request body
 	var req notification.Request
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.logger.Warn("invalid request body",
+	if status, err := decodeJSONBody(w, r, h.maxBody, &req); err != nil {
+		logger.Warn("invalid request body",
 			slog.String("error", err.Error()),
 		)
-		WriteError(w, http.StatusBadRequest, "invalid request body")
+		WriteError(w, status, err.Error())
 		return
 	}
 
-	// Log incoming request
-	h.logger.Info("incoming notification request",
+	// Log incoming request; the message body is only logged at debug level
+	attrs := []any{
+		slog.String("api_key_id", apiKey.ID),
+		slog.String("template", req.Template),
 		slog.String("title", req.Title),
-		slog.String("message", req.Message),
 		slog.String("level", string(req.Level)),
 		slog.Any("channels", req.Channels),
 		slog.String("source", req.Source),
-	)
+		slog.Any("tags", req.Tags),
+	}
+	if logger.Enabled(r.Context(), slog.LevelDebug) {
+		attrs = append(attrs, slog.String("message", req.Message))
+	} else {
+		attrs = append(attrs, slog.Int("message_length", len(req.Message)))
+	}
+	logger.Info("incoming notification request", attrs...)
+
+	// Use one policy snapshot for the whole request
+	policy := h.policy.Load()
+
+	// Render the template into the title and message
+	if req.Template != "" {
+		_, span := tracer.Start(r.Context(), "render_template",
+			trace.WithAttributes(attribute.String("template", req.Template)))
+		err := policy.Templates.Apply(&req)
+		if err != nil {
+			tracing.Fail(span, err)
+		}
+		span.End()
+		if err != nil {
+			logger.Warn("failed to render template",
+				slog.String("template", req.Template),
+				slog.String("error", err.Error()),
+			)
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	} else if len(req.Vars) > 0 {
+		WriteError(w, http.StatusBadRequest, "vars require a template")
+		return
+	}
+
+	// Resolve channels from routing rules when the caller did not list any
+	var digestChannels []notification.Channel
+	if len(req.Channels) == 0 {
+		var rules []string
+		req.Channels, rules, digestChannels = policy.Routes.Resolve(&req, apiKey.ID)
+		logger.Info("channels resolved by routing rules",
+			slog.Any("channels", req.Channels),
+			slog.Any("rules", rules),
+		)
+	}
 
 	// Validate request
-	if err := h.validator.Validate(&req); err != nil {
-		h.logger.Warn("validation failed",
+	_, span := tracer.Start(r.Context(), "validate")
+	if err := policy.Validator.Validate(&req); err != nil {
+		tracing.Fail(span, err)
+		span.End()
+		logger.Warn("validation failed",
 			slog.String("error", err.Error()),
 		)
+		var verr *notification.ValidationError
+		if errors.As(err, &verr) {
+			WriteJSON(w, http.StatusBadRequest, notification.ErrorResponse{
+				Error:             notification.ErrValidationFailed.Error(),
+				Fields:            verr.Fields,
+				AvailableChannels: verr.AvailableChannels,
+			})
+			return
+		}
 		WriteError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	span.End()
+
+	// Default to the level's priority, capped at what the key may use
+	if req.Priority == "" {
+		req.Priority = policy.Levels.Get(req.Level).Priority
+		if limit := notification.Priority(apiKey.Scopes.MaxPriority); limit != "" && req.Priority.Rank() > limit.Rank() {
+			req.Priority = limit
+		}
+	}
+
+	// Check API key scopes against the concrete channels
+	_, span = tracer.Start(r.Context(), "authorize")
+	targets := policy.Groups.Expand(req.Channels)
+	if err := CheckScopes(apiKey, &req, targets, policy.Groups.Expand); err != nil {
+		tracing.Fail(span, err)
+		span.End()
+		logger.Warn("request outside API key scopes",
+			slog.String("api_key_id", apiKey.ID),
+			slog.String("reason", err.Error()),
+		)
+		WriteError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	span.End()
+
+	// Drop channels below their minimum level; hold or silence the others
+	// during their quiet hours
+	targets, skipped := policy.Filters.Apply(&req, targets, time.Now())
+	var skippedDeliveries []notification.Delivery
+	for _, ch := range skipped {
+		skippedDeliveries = append(skippedDeliveries, notification.Delivery{
+			Channel: ch,
+			Status:  notification.DeliverySkipped,
+			Error:   "below the channel's minimum level",
+		})
+	}
+	if len(skipped) > 0 {
+		logger.Info("channels filtered out by level",
+			slog.String("level", string(req.Level)),
+			slog.Any("channels", skipped),
+		)
+	}
+	if len(targets) == 0 {
+		WriteJSON(w, http.StatusOK, notification.Response{
+			Status:     notification.StatusSkipped,
+			Deliveries: skippedDeliveries,
+		})
+		return
+	}
 
-	// Check rate limits
-	allowed, blockedChannel := CheckRateLimit(h.limiter, apiKey, req.Channels)
+	// Batch into digests on the channels of matching digest rules, except
+	// levels the channel lets through its quiet hours
+	for _, ch := range policy.Groups.Expand(digestChannels) {
+		if slices.Contains(targets, ch) && !policy.Filters.Bypasses(ch, req.Level) {
+			if req.Schedules == nil {
+				req.Schedules = make(map[notification.Channel]notification.Schedule)
+			}
+			schedule := req.Schedules[ch]
+			schedule.Digest = true
+			req.Schedules[ch] = schedule
+		}
+	}
+
+	// Suppress repeats of a notification sent within the dedup window; they
+	// are counted for the follow-up and do not use up the rate limit
+	var fingerprint string
+	if h.dedup != nil {
+		fingerprint = h.dedup.Fingerprint(&req)
+		repeats, err := h.dedup.Check(r.Context(), apiKey.ID, fingerprint)
+		if err != nil {
+			// Deliver rather than drop when Redis is unavailable
+			logger.Warn("failed to check for repeats",
+				slog.String("fingerprint", fingerprint),
+				slog.String("error", err.Error()),
+			)
+		} else if repeats > 0 {
+			logger.Info("repeated notification suppressed",
+				slog.String("fingerprint", fingerprint),
+				slog.Int("repeats", repeats),
+			)
+			WriteJSON(w, http.StatusOK, notification.Response{
+				Status:     notification.StatusDuplicate,
+				Deliveries: []notification.Delivery{},
+				Repeats:    repeats,
+			})
+			return
+		}
+	}
+
+	// Mute a source that floods; repeats suppressed above are not counted
+	if h.flood != nil {
+		state, err := h.flood.Check(r.Context(), &req, apiKey.ID, policy.Levels)
+		switch {
+		case err != nil:
+			// Deliver rather than drop when Redis is unavailable
+			logger.Warn("failed to check flood rate",
+				slog.String("source", req.Source),
+				slog.String("error", err.Error()),
+			)
+		case state == flood.Muted:
+			logger.Info("notification from muted source suppressed",
+				slog.String("source", req.Source),
+			)
+			WriteJSON(w, http.StatusOK, notification.Response{
+				Status:     notification.StatusMuted,
+				Deliveries: []notification.Delivery{},
+			})
+			return
+		case state == flood.Tripped:
+			if err := h.flood.Announce(r.Context(), &req, targets, apiKey.ID, requestID); err != nil {
+				logger.Warn("failed to mute source",
+					slog.String("source", req.Source),
+					slog.String("error", err.Error()),
+				)
+			}
+		}
+	}
+
+	// Check rate limits against the concrete channels
+	_, span = tracer.Start(r.Context(), "rate_limit")
+	allowed, blockedChannel := CheckRateLimit(h.limiter, apiKey.ID, targets)
 	if !allowed {
-		h.logger.Warn("rate limit exceeded",
-			slog.String("api_key", maskAPIKey(apiKey)),
+		span.SetAttributes(attribute.String("blocked_channel", blockedChannel))
+		span.SetStatus(codes.Error, "rate limit exceeded")
+		span.End()
+		logger.Warn("rate limit exceeded",
+			slog.String("api_key_id", apiKey.ID),
 			slog.String("blocked_channel", blockedChannel),
 		)
 		WriteError(w, http.StatusTooManyRequests, fmt.Sprintf("rate limit exceeded for channel: %s", blockedChannel))
 		return
 	}
+	span.End()
 
-	// Enqueue notifications
-	taskIDs, err := h.client.Enqueue(&req, apiKey)
-	if err != nil {
-		h.logger.Error("failed to enqueue notification",
-			slog.String("error", err.Error()),
-		)
-		WriteError(w, http.StatusInternalServerError, "failed to queue notification")
-		return
+	// Enqueue notifications; each channel is reported separately.
+	// Pass the concrete channels checked above so a concurrent config reload
+	// cannot change what gets enqueued.
+	req.Channels = targets
+	ctx, span := tracer.Start(r.Context(), "enqueue")
+	deliveries := h.client.Enqueue(ctx, &req, apiKey.ID, requestID)
+
+	queued := 0
+	for _, d := range deliveries {
+		if d.Status == notification.DeliveryQueued {
+			queued++
+		}
+	}
+	span.SetAttributes(attribute.Int("channels", len(deliveries)), attribute.Int("queued", queued))
+	if queued < len(deliveries) {
+		span.SetStatus(codes.Error, "some channels failed to enqueue")
+	}
+	span.End()
+
+	// Open the dedup window for what was queued, except on channels that an
+	// active silence mutes: the worker drops those, and their follow-up would
+	// only arrive once the silence ends
+	if h.dedup != nil && queued > 0 {
+		var queuedChannels []notification.Channel
+		for _, d := range deliveries {
+			if d.Status == notification.DeliveryQueued && !h.silenced(r.Context(), &req, d.Channel, apiKey.ID, logger) {
+				queuedChannels = append(queuedChannels, d.Channel)
+			}
+		}
+		if len(queuedChannels) > 0 {
+			if err := h.dedup.Record(r.Context(), &req, queuedChannels, apiKey.ID, fingerprint, requestID); err != nil {
+				logger.Warn("failed to record notification for deduplication",
+					slog.String("fingerprint", fingerprint),
+					slog.String("error", err.Error()),
+				)
+			}
+		}
 	}
 
-	// Return first task ID (or comma-separated if multiple)
-	responseID := strings.Join(taskIDs, ",")
+	// Filtered channels are reported but do not count as failures
+	failed := len(deliveries) - queued
+	deliveries = append(deliveries, skippedDeliveries...)
 
-	WriteJSON(w, http.StatusAccepted, notification.Response{
-		Status: "queued",
-		ID:     responseID,
+	switch {
+	case failed == 0:
+		WriteJSON(w, http.StatusAccepted, notification.Response{
+			Status:     notification.StatusQueued,
+			Deliveries: deliveries,
+		})
+	case queued > 0:
+		// Some channels are already queued; retrying the whole request would
+		// duplicate them, so report exactly which ones failed
+		logger.Warn("notification partially queued",
+			slog.Int("queued", queued),
+			slog.Int("failed", failed),
+		)
+		WriteJSON(w, http.StatusMultiStatus, notification.Response{
+			Status:     notification.StatusPartial,
+			Deliveries: deliveries,
+		})
+	default:
+		logger.Error("failed to enqueue notification",
+			slog.Int("channels", failed),
+		)
+		WriteJSON(w, http.StatusInternalServerError, notification.Response{
+			Status:     notification.StatusFailed,
+			Deliveries: deliveries,
+		})
+	}
+}
+
+// silenced reports whether an active silence mutes req on ch. As in the
+// worker, nothing counts as silenced when the silences cannot be read.
+func (h *Handler) silenced(ctx context.Context, req *notification.Request, ch notification.Channel, apiKeyID string, logger *slog.Logger) bool {
+	if h.silences == nil {
+		return false
+	}
+	sil, err := h.silences.Match(ctx, &notification.Notification{
+		Title:    req.Title,
+		Level:    req.Level,
+		Channel:  ch,
+		APIKeyID: apiKeyID,
+		Source:   req.Source,
+		Tags:     req.Tags,
 	})
+	if err != nil {
+		logger.Warn("failed to check silences",
+			slog.String("channel", string(ch)),
+			slog.String("error", err.Error()),
+		)
+		return false
+	}
+	return sil != nil
 }
 
 // HandleHealth handles GET /health requests
@@ -123,15 +405,47 @@ func WriteJSON(w http.ResponseWriter, status int, data interface{}) {
 	json.NewEncoder(w).Encode(data)
 }
 
+// decodeJSONBody strictly decodes a single JSON object from the request body
+// into dst, rejecting unknown fields and bodies larger than maxBytes.
+// On failure it returns the HTTP status to respond with and a client-facing error.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, maxBytes int64, dst interface{}) (int, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		var maxErr *http.MaxBytesError
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+
+		switch {
+		case errors.As(err, &maxErr):
+			return http.StatusRequestEntityTooLarge, fmt.Errorf("request body must not exceed %d bytes", maxErr.Limit)
+		case errors.As(err, &syntaxErr):
+			return http.StatusBadRequest, fmt.Errorf("invalid request body: malformed JSON at offset %d", syntaxErr.Offset)
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return http.StatusBadRequest, errors.New("invalid request body: malformed JSON")
+		case errors.As(err, &typeErr):
+			return http.StatusBadRequest, fmt.Errorf("invalid request body: field %q must be of type %s", typeErr.Field, typeErr.Type)
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			field := strings.TrimPrefix(err.Error(), "json: unknown field ")
+			return http.StatusBadRequest, fmt.Errorf("invalid request body: unknown field %s", field)
+		case errors.Is(err, io.EOF):
+			return http.StatusBadRequest, errors.New("invalid request body: body must not be empty")
+		default:
+			return http.StatusBadRequest, errors.New("invalid request body")
+		}
+	}
+
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return http.StatusBadRequest, errors.New("invalid request body: must contain a single JSON object")
+	}
+
+	return 0, nil
+}
+
 // WriteError writes an error response
 func WriteError(w http.ResponseWriter, status int, message string) {
 	WriteJSON(w, status, notification.ErrorResponse{Error: message})
 }
-
-// maskAPIKey masks an API key for logging (shows first 4 and last 4 chars)
-func maskAPIKey(key string) string {
-	if len(key) <= 8 {
-		return "****"
-	}
-	return key[:4] + "****" + key[len(key)-4:]
-}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/luytbq/personal-notification-service/internal/channels"
+	"github.com/luytbq/personal-notification-service/internal/config"
+	"github.com/luytbq/personal-notification-service/internal/dedup"
+	"github.com/luytbq/personal-notification-service/internal/notification"
+	"github.com/luytbq/personal-notification-service/internal/queue"
+	"github.com/luytbq/personal-notification-service/internal/ratelimit"
+	"github.com/luytbq/personal-notification-service/internal/silence"
+	"github.com/luytbq/personal-notification-service/internal/stream"
+)
+
+// newTestHandler returns a handler for cfg that enqueues to an in-memory
+// Redis, with a telegram channel and a webhook:ops target registered
+func newTestHandler(t *testing.T, cfg *config.Config) (*Handler, *miniredis.Miniredis) {
+	t.Helper()
+	redis := miniredis.RunT(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	registry := channels.NewRegistry()
+	registry.Register(&recordingChannel{})
+	registry.Register(channels.NewWebhookChannel("ops", "https://hooks.example.com/ops", "secret"))
+
+	policy, err := NewPolicy(cfg, registry)
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+	holder := &PolicyHolder{}
+	holder.Store(policy)
+
+	hub := stream.NewHub(redis.Addr(), "", 0, "pns", logger)
+	client := queue.NewClient(redis.Addr(), "", 0, 0, logger, queue.NewQueueNames("pns"), policy.Groups, nil, hub)
+	t.Cleanup(func() { client.Close() })
+	return NewHandler(holder, ratelimit.NewLimiter(600), client, nil, nil, nil, 64<<10, logger), redis
+}
+
+// notify posts body to the handler and returns the decoded response
+func notify(t *testing.T, h *Handler, body string) notification.Response {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/notify", strings.NewReader(body))
+	req = req.WithContext(context.WithValue(req.Context(), APIKeyContextKey, &config.APIKey{ID: "test"}))
+	rec := httptest.NewRecorder()
+	h.HandleNotify(rec, req)
+	if rec.Code != http.StatusAccepted && rec.Code != http.StatusOK {
+		t.Fatalf("HandleNotify status = %d, body %s", rec.Code, rec.Body)
+	}
+	var resp notification.Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return resp
+}
+
+// TestDigestRuleBypassLevel checks that a digest rule batches only levels
+// below the bypass level of each channel
+func TestDigestRuleBypassLevel(t *testing.T) {
+	cfg := &config.Config{
+		Routing: config.RoutingConfig{Rules: []config.RouteRule{
+			{Name: "everything", Channels: []string{"telegram", "webhook:ops"}, Digest: true},
+		}},
+		ChannelFilters: map[string]config.ChannelFilterConfig{
+			"webhook:ops": {QuietHours: config.QuietHoursConfig{BypassLevel: "error"}},
+		},
+	}
+	h, _ := newTestHandler(t, cfg)
+
+	tests := []struct {
+		level notification.Level
+		want  map[notification.Channel]bool // whether each channel batches
+	}{
+		{notification.LevelInfo, map[notification.Channel]bool{"telegram": true, "webhook:ops": true}},
+		{notification.LevelError, map[notification.Channel]bool{"telegram": true, "webhook:ops": false}},
+		{notification.LevelCritical, map[notification.Channel]bool{"telegram": false, "webhook:ops": false}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.level), func(t *testing.T) {
+			resp := notify(t, h, `{"title": "Disk full", "message": "/var", "level": "`+string(tt.level)+`"}`)
+			if len(resp.Deliveries) != len(tt.want) {
+				t.Fatalf("got %d deliveries, want %d: %+v", len(resp.Deliveries), len(tt.want), resp.Deliveries)
+			}
+			for _, d := range resp.Deliveries {
+				if d.Digest != tt.want[d.Channel] {
+					t.Errorf("%s digest = %t, want %t", d.Channel, d.Digest, tt.want[d.Channel])
+				}
+			}
+		})
+	}
+}
+
+// TestDedupSkipsSilencedNotifications checks that a silenced notification
+// opens no dedup window, whose follow-up would arrive after the silence
+func TestDedupSkipsSilencedNotifications(t *testing.T) {
+	h, redis := newTestHandler(t, &config.Config{})
+	h.dedup = dedup.NewDeduper(redis.Addr(), "", 0, "pns", time.Minute, []string{"source", "title"}, h.client, h.logger)
+	t.Cleanup(func() { h.dedup.Close() })
+	h.silences = silence.NewStore(redis.Addr(), "", 0, "pns", h.logger)
+	t.Cleanup(func() { h.silences.Close() })
+
+	err := h.silences.Create(context.Background(), &silence.Silence{
+		Matchers:  silence.Matchers{Source: "backup-job"},
+		StartsAt:  time.Now().Add(-time.Minute),
+		EndsAt:    time.Now().Add(time.Hour),
+		CreatedBy: "test",
+		Comment:   "maintenance",
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	tests := []struct {
+		source string
+		want   string // status of the repeat
+	}{
+		{"backup-job", notification.StatusQueued},
+		{"deploy", notification.StatusDuplicate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			body := `{"title": "Disk full", "message": "/var", "level": "error", "channel": ["telegram"], "source": "` + tt.source + `"}`
+			if resp := notify(t, h, body); resp.Status != notification.StatusQueued {
+				t.Fatalf("first status = %s, want %s", resp.Status, notification.StatusQueued)
+			}
+			if resp := notify(t, h, body); resp.Status != tt.want {
+				t.Errorf("repeat status = %s, want %s", resp.Status, tt.want)
+			}
+		})
+	}
+}
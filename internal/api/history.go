@@ -0,0 +1,118 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/luytbq/personal-notification-service/internal/history"
+	"github.com/luytbq/personal-notification-service/internal/notification"
+)
+
+// HistoryHandler serves the notification history
+type HistoryHandler struct {
+	store  history.Store
+	policy *PolicyHolder
+	logger *slog.Logger
+}
+
+// NewHistoryHandler creates a new HistoryHandler
+func NewHistoryHandler(store history.Store, policy *PolicyHolder, logger *slog.Logger) *HistoryHandler {
+	return &HistoryHandler{
+		store:  store,
+		policy: policy,
+		logger: logger,
+	}
+}
+
+// HandleList handles GET /notifications. Keys without the admin scope only
+// see notifications they sent.
+func (h *HistoryHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	apiKey := GetAPIKey(r.Context())
+	if apiKey == nil {
+		WriteError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	q, err := parseHistoryQuery(r, h.policy.Load().Levels)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !apiKey.Scopes.Admin {
+		q.APIKeyID = apiKey.ID
+	}
+
+	page, err := h.store.List(r.Context(), q)
+	if err != nil {
+		if errors.Is(err, history.ErrInvalidCursor) {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		h.logger.Error("failed to query notification history",
+			slog.String("request_id", GetRequestID(r.Context())),
+			slog.String("error", err.Error()),
+		)
+		WriteError(w, http.StatusInternalServerError, "failed to query notification history")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, page)
+}
+
+// parseHistoryQuery reads the filters of GET /notifications
+func parseHistoryQuery(r *http.Request, levels *notification.Levels) (history.Query, error) {
+	params := r.URL.Query()
+	q := history.Query{
+		Source:  params.Get("source"),
+		Level:   notification.Level(params.Get("level")),
+		Channel: notification.Channel(params.Get("channel")),
+		Status:  history.Status(params.Get("status")),
+		Text:    params.Get("q"),
+		Cursor:  params.Get("cursor"),
+	}
+
+	if q.Level != "" && !levels.Has(q.Level) {
+		return q, levels.InvalidError()
+	}
+	if q.Status != "" && !q.Status.IsValid() {
+		names := make([]string, len(history.Statuses))
+		for i, s := range history.Statuses {
+			names[i] = string(s)
+		}
+		return q, fmt.Errorf("invalid status: must be one of %s", strings.Join(names, ", "))
+	}
+
+	var err error
+	if q.Since, err = parseTimeParam(params.Get("since"), "since"); err != nil {
+		return q, err
+	}
+	if q.Until, err = parseTimeParam(params.Get("until"), "until"); err != nil {
+		return q, err
+	}
+
+	if s := params.Get("limit"); s != "" {
+		q.Limit, err = strconv.Atoi(s)
+		if err != nil || q.Limit < 1 || q.Limit > history.MaxLimit {
+			return q, fmt.Errorf("invalid limit: must be between 1 and %d", history.MaxLimit)
+		}
+	}
+
+	return q, nil
+}
+
+// parseTimeParam parses an RFC 3339 time; empty means unset
+func parseTimeParam(s, name string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s: must be an RFC 3339 time, e.g. 2024-01-15T10:30:00Z", name)
+	}
+	return t, nil
+}
@@ -2,10 +2,14 @@ package api
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/luytbq/personal-notification-service/internal/config"
 	"github.com/luytbq/personal-notification-service/internal/notification"
 	"github.com/luytbq/personal-notification-service/internal/ratelimit"
@@ -15,25 +19,86 @@ import (
 type contextKey string
 
 const (
-	// APIKeyContextKey is the context key for the authenticated API key
+	// APIKeyContextKey is the context key for the authenticated API key identity
 	APIKeyContextKey contextKey = "api_key"
+
+	// RequestIDContextKey is the context key for the request ID
+	RequestIDContextKey contextKey = "request_id"
 )
 
-// GetAPIKey extracts the API key from context
-func GetAPIKey(ctx context.Context) string {
+// RequestIDHeader carries the request ID in requests, responses and outbound webhooks
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds caller-supplied request IDs
+const maxRequestIDLength = 128
+
+// GetAPIKey extracts the authenticated API key identity from context
+func GetAPIKey(ctx context.Context) *config.APIKey {
 	if v := ctx.Value(APIKeyContextKey); v != nil {
-		return v.(string)
+		return v.(*config.APIKey)
+	}
+	return nil
+}
+
+// GetRequestID extracts the request ID from context
+func GetRequestID(ctx context.Context) string {
+	id, _ := ctx.Value(RequestIDContextKey).(string)
+	return id
+}
+
+// RequestIDMiddleware assigns every request an ID and returns it in the
+// X-Request-ID response header. A well-formed X-Request-ID sent by the caller
+// is kept, so callers can correlate their own logs with ours.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.New().String()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		ctx := context.WithValue(r.Context(), RequestIDContextKey, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// validRequestID accepts short IDs made of letters, digits and -_.:/
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.ContainsRune("-_.:/", c):
+		default:
+			return false
+		}
 	}
-	return ""
+	return true
 }
 
-// AuthMiddleware validates the X-API-Key header
-func AuthMiddleware(cfg *config.Config, logger *slog.Logger) func(http.Handler) http.Handler {
+// KeyLookup resolves a raw API key to its identity
+type KeyLookup interface {
+	LookupAPIKey(key string) (*config.APIKey, bool)
+}
+
+// AuthMiddleware validates the X-API-Key header. Browsers may instead send
+// the key as the password of HTTP Basic auth (the user name is ignored), which
+// is how the dashboard is opened.
+func AuthMiddleware(keys KeyLookup, logger *slog.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			apiKey := strings.TrimSpace(r.Header.Get("X-API-Key"))
 			if apiKey == "" {
+				if _, password, ok := r.BasicAuth(); ok {
+					apiKey = password
+				}
+			}
+			if apiKey == "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="pns", charset="UTF-8"`)
 				logger.Warn("missing API key",
+					slog.String("request_id", GetRequestID(r.Context())),
 					slog.String("remote_addr", r.RemoteAddr),
 					slog.String("path", r.URL.Path),
 				)
@@ -41,8 +106,10 @@ func AuthMiddleware(cfg *config.Config, logger *slog.Logger) func(http.Handler)
 				return
 			}
 
-			if !cfg.ValidateAPIKey(apiKey) {
+			key, ok := keys.LookupAPIKey(apiKey)
+			if !ok {
 				logger.Warn("invalid API key",
+					slog.String("request_id", GetRequestID(r.Context())),
 					slog.String("remote_addr", r.RemoteAddr),
 					slog.String("path", r.URL.Path),
 				)
@@ -50,24 +117,95 @@ func AuthMiddleware(cfg *config.Config, logger *slog.Logger) func(http.Handler)
 				return
 			}
 
-			// Store API key in context for later use
-			ctx := context.WithValue(r.Context(), APIKeyContextKey, apiKey)
+			if key.Expired(time.Now()) {
+				logger.Warn("expired API key",
+					slog.String("request_id", GetRequestID(r.Context())),
+					slog.String("api_key_id", key.ID),
+					slog.String("remote_addr", r.RemoteAddr),
+					slog.String("path", r.URL.Path),
+				)
+				WriteError(w, http.StatusUnauthorized, "API key expired")
+				return
+			}
+
+			// Store the key identity, not the raw key, in context for later use
+			ctx := context.WithValue(r.Context(), APIKeyContextKey, key)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
-// CheckRateLimit checks rate limits for the given API key and channels
+// CheckRateLimit checks rate limits for the given API key ID and channels
 // Returns true if allowed, false if rate limited along with the blocked channel
-func CheckRateLimit(limiter *ratelimit.Limiter, apiKey string, channels []notification.Channel) (bool, string) {
+func CheckRateLimit(limiter *ratelimit.Limiter, apiKeyID string, channels []notification.Channel) (bool, string) {
 	for _, ch := range channels {
-		if !limiter.Allow(apiKey, string(ch)) {
+		if !limiter.Allow(apiKeyID, string(ch)) {
 			return false, string(ch)
 		}
 	}
 	return true, ""
 }
 
+// AdminMiddleware requires the authenticated API key to have the admin scope.
+// It must run after AuthMiddleware.
+func AdminMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := GetAPIKey(r.Context())
+			if key == nil || !key.Scopes.Admin {
+				if key != nil {
+					logger.Warn("admin access denied",
+						slog.String("request_id", GetRequestID(r.Context())),
+						slog.String("api_key_id", key.ID),
+						slog.String("path", r.URL.Path),
+					)
+				}
+				WriteError(w, http.StatusForbidden, "API key does not have the admin scope")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// CheckScopes verifies that a request stays within the scopes of its API key.
+// targets are the concrete channels of the request and expand resolves
+// channel groups listed in the scopes. Returns a reason when it does not.
+func CheckScopes(key *config.APIKey, req *notification.Request, targets []notification.Channel, expand func([]notification.Channel) []notification.Channel) error {
+	scopes := key.Scopes
+
+	if len(scopes.Channels) > 0 {
+		allowed := make([]notification.Channel, 0, len(scopes.Channels))
+		for _, ch := range scopes.Channels {
+			allowed = append(allowed, notification.Channel(ch))
+		}
+		allowed = expand(allowed)
+		for _, ch := range targets {
+			if !slices.Contains(allowed, ch) {
+				return fmt.Errorf("API key is not allowed to send to channel %s", ch)
+			}
+		}
+	}
+
+	if len(scopes.Levels) > 0 && !slices.Contains(scopes.Levels, string(req.Level)) {
+		return fmt.Errorf("API key is not allowed to send level %s", req.Level)
+	}
+
+	if scopes.MaxPriority != "" && req.Priority.Rank() > notification.Priority(scopes.MaxPriority).Rank() {
+		return fmt.Errorf("API key is not allowed to send priority %s (max %s)", req.Priority, scopes.MaxPriority)
+	}
+
+	if len(scopes.Recipients) > 0 {
+		for ch, recipient := range req.Recipients {
+			if !slices.Contains(scopes.Recipients, recipient) {
+				return fmt.Errorf("API key is not allowed to override the %s recipient with %s", ch, recipient)
+			}
+		}
+	}
+
+	return nil
+}
+
 // LoggingMiddleware logs HTTP requests
 func LoggingMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -79,6 +217,7 @@ func LoggingMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
 			}
 
 			logger.Info("request received",
+				slog.String("request_id", GetRequestID(r.Context())),
 				slog.String("method", r.Method),
 				slog.String("path", r.URL.Path),
 				slog.String("remote_addr", r.RemoteAddr),
@@ -97,6 +236,7 @@ func RecoveryMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
 			defer func() {
 				if err := recover(); err != nil {
 					logger.Error("panic recovered",
+						slog.String("request_id", GetRequestID(r.Context())),
 						slog.Any("error", err),
 						slog.String("path", r.URL.Path),
 					)
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/luytbq/personal-notification-service/internal/channels"
+	"github.com/luytbq/personal-notification-service/internal/config"
+	"github.com/luytbq/personal-notification-service/internal/filters"
+	"github.com/luytbq/personal-notification-service/internal/notification"
+	"github.com/luytbq/personal-notification-service/internal/routing"
+	"github.com/luytbq/personal-notification-service/internal/templates"
+)
+
+// Policy is the reloadable part of request handling: levels, channel groups,
+// routing rules, channel filters, message templates and the validator built
+// from them. It is swapped as a whole so every request sees one consistent
+// version.
+type Policy struct {
+	Levels    *notification.Levels
+	Groups    notification.Groups
+	Routes    *routing.Engine
+	Filters   *filters.Set
+	Templates *templates.Set
+	Validator *notification.Validator
+}
+
+// PolicyHolder holds the current Policy
+type PolicyHolder = atomic.Pointer[Policy]
+
+// NewPolicy builds a Policy from configuration, validating levels, groups,
+// routing rules, channel filters and templates
+func NewPolicy(cfg *config.Config, registry *channels.Registry) (*Policy, error) {
+	levels, err := cfg.Levels.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := notification.NewGroups(cfg.ChannelGroups)
+	if err != nil {
+		return nil, fmt.Errorf("invalid channel groups: %w", err)
+	}
+
+	routes, err := routing.NewEngine(cfg.Routing, groups, levels)
+	if err != nil {
+		return nil, fmt.Errorf("invalid routing rules: %w", err)
+	}
+
+	chFilters, err := filters.NewSet(cfg.ChannelFilters, levels)
+	if err != nil {
+		return nil, fmt.Errorf("invalid channel filters: %w", err)
+	}
+
+	tmpls, err := templates.NewSet(cfg.Templates, groups, levels)
+	if err != nil {
+		return nil, fmt.Errorf("invalid templates: %w", err)
+	}
+
+	return &Policy{
+		Levels:    levels,
+		Groups:    groups,
+		Routes:    routes,
+		Filters:   chFilters,
+		Templates: tmpls,
+		Validator: notification.NewValidator(groups, registry, levels),
+	}, nil
+}
@@ -6,33 +6,88 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/luytbq/personal-notification-service/internal/admin"
+	"github.com/luytbq/personal-notification-service/internal/channels"
 	"github.com/luytbq/personal-notification-service/internal/config"
-	"github.com/luytbq/personal-notification-service/internal/notification"
+	"github.com/luytbq/personal-notification-service/internal/dashboard"
+	"github.com/luytbq/personal-notification-service/internal/dedup"
+	"github.com/luytbq/personal-notification-service/internal/flood"
+	"github.com/luytbq/personal-notification-service/internal/history"
+	"github.com/luytbq/personal-notification-service/internal/logging"
 	"github.com/luytbq/personal-notification-service/internal/queue"
 	"github.com/luytbq/personal-notification-service/internal/ratelimit"
+	"github.com/luytbq/personal-notification-service/internal/silence"
+	"github.com/luytbq/personal-notification-service/internal/stream"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 // NewRouter creates and configures the HTTP router
-func NewRouter(cfg *config.Config, limiter *ratelimit.Limiter, client *queue.Client, logger *slog.Logger) http.Handler {
+func NewRouter(cfg *config.Config, limiter *ratelimit.Limiter, client *queue.Client, policy *PolicyHolder, keys KeyLookup, manager *admin.Manager, levels *logging.Levels, store history.Store, inspector *queue.Inspector, registry *channels.Registry, hub *stream.Hub, deduper *dedup.Deduper, muter *flood.Muter, silences *silence.Store, logger *slog.Logger) http.Handler {
 	r := chi.NewRouter()
 
 	// Global middleware
-	r.Use(middleware.RequestID)
+	r.Use(RequestIDMiddleware)
 	r.Use(middleware.RealIP)
+	r.Use(otelhttp.NewMiddleware("http",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + r.URL.Path
+		}),
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			return r.URL.Path != "/notify/health"
+		}),
+	))
 	r.Use(RecoveryMiddleware(logger))
 	r.Use(LoggingMiddleware(logger))
 
 	// Create handler
-	validator := notification.NewValidator()
-	handler := NewHandler(validator, limiter, client, logger)
+	handler := NewHandler(policy, limiter, client, deduper, muter, silences, cfg.Server.MaxRequestBytes, logger)
+	adminHandler := NewAdminHandler(manager, levels, cfg.Server.MaxRequestBytes, logger)
+	dashboardHandler := NewDashboardHandler(inspector, registry, limiter, logger)
+	silenceHandler := NewSilenceHandler(silences, policy, cfg.Server.MaxRequestBytes, logger)
 
 	// Public routes (no auth required)
 	r.Get("/notify/health", handler.HandleHealth)
 
 	// Protected routes
 	r.Group(func(r chi.Router) {
-		r.Use(AuthMiddleware(cfg, logger))
+		r.Use(AuthMiddleware(keys, logger))
 		r.Post("/notify", handler.HandleNotify)
+
+		// Notification history, when enabled
+		if store != nil {
+			r.Get("/notifications", NewHistoryHandler(store, policy, logger).HandleList)
+		}
+
+		// Live notification stream (SSE or WebSocket)
+		r.Get("/notifications/stream", NewStreamHandler(hub, policy, logger).HandleStream)
+
+		// Admin routes require the admin scope
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(AdminMiddleware(logger))
+			r.Get("/keys", adminHandler.HandleListKeys)
+			r.Post("/keys", adminHandler.HandleCreateKey)
+			r.Delete("/keys/{id}", adminHandler.HandleRevokeKey)
+			r.Get("/targets", adminHandler.HandleListTargets)
+			r.Put("/targets/{channel}", adminHandler.HandlePutTarget)
+			r.Delete("/targets/{channel}", adminHandler.HandleDeleteTarget)
+			r.Get("/log-level", adminHandler.HandleGetLogLevel)
+			r.Put("/log-level", adminHandler.HandleSetLogLevel)
+			r.Get("/queues", dashboardHandler.HandleQueues)
+			r.Get("/dead-letters", dashboardHandler.HandleDeadLetters)
+			r.Post("/dead-letters/{id}/retry", dashboardHandler.HandleRetryDeadLetter)
+			r.Get("/channels", dashboardHandler.HandleChannelHealth)
+			r.Get("/rate-limits", dashboardHandler.HandleRateLimits)
+			r.Get("/silences", silenceHandler.HandleList)
+			r.Post("/silences", silenceHandler.HandleCreate)
+			r.Delete("/silences/{id}", silenceHandler.HandleExpire)
+		})
+
+		// Web dashboard, also admin only
+		r.Group(func(r chi.Router) {
+			r.Use(AdminMiddleware(logger))
+			r.Get("/dashboard", http.RedirectHandler("/dashboard/", http.StatusMovedPermanently).ServeHTTP)
+			r.Handle("/dashboard/*", http.StripPrefix("/dashboard", dashboard.Handler()))
+		})
 	})
 
 	return r
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/luytbq/personal-notification-service/internal/silence"
+)
+
+// SilenceHandler serves the /admin/silences API
+type SilenceHandler struct {
+	store   *silence.Store
+	policy  *PolicyHolder
+	logger  *slog.Logger
+	maxBody int64
+}
+
+// NewSilenceHandler creates a new SilenceHandler
+func NewSilenceHandler(store *silence.Store, policy *PolicyHolder, maxBody int64, logger *slog.Logger) *SilenceHandler {
+	return &SilenceHandler{
+		store:   store,
+		policy:  policy,
+		logger:  logger,
+		maxBody: maxBody,
+	}
+}
+
+// createSilenceRequest is the body of POST /admin/silences. starts_at
+// defaults to now and created_by to the ID of the calling API key.
+type createSilenceRequest struct {
+	Matchers  silence.Matchers `json:"matchers"`
+	StartsAt  *time.Time       `json:"starts_at,omitempty"`
+	EndsAt    time.Time        `json:"ends_at"`
+	CreatedBy string           `json:"created_by"`
+	Comment   string           `json:"comment"`
+}
+
+// silenceResponse is a silence with its current state
+type silenceResponse struct {
+	*silence.Silence
+	State string `json:"state"`
+}
+
+// HandleList handles GET /admin/silences. ?state=pending|active|expired
+// selects silences in that state.
+func (h *SilenceHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	state := r.URL.Query().Get("state")
+	switch state {
+	case "", silence.StatePending, silence.StateActive, silence.StateExpired:
+	default:
+		WriteError(w, http.StatusBadRequest, "state must be pending, active or expired")
+		return
+	}
+
+	silences, err := h.store.List(r.Context())
+	if err != nil {
+		h.logger.Error("failed to list silences", slog.String("error", err.Error()))
+		WriteError(w, http.StatusInternalServerError, "failed to list silences")
+		return
+	}
+
+	now := time.Now()
+	resp := make([]silenceResponse, 0, len(silences))
+	for _, s := range silences {
+		if state == "" || s.State(now) == state {
+			resp = append(resp, silenceResponse{Silence: s, State: s.State(now)})
+		}
+	}
+	WriteJSON(w, http.StatusOK, map[string]interface{}{"silences": resp})
+}
+
+// HandleCreate handles POST /admin/silences
+func (h *SilenceHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	var req createSilenceRequest
+	if status, err := decodeJSONBody(w, r, h.maxBody, &req); err != nil {
+		WriteError(w, status, err.Error())
+		return
+	}
+
+	apiKeyID := GetAPIKey(r.Context()).ID
+	s := &silence.Silence{
+		Matchers:  req.Matchers,
+		StartsAt:  time.Now(),
+		EndsAt:    req.EndsAt,
+		CreatedBy: req.CreatedBy,
+		Comment:   req.Comment,
+	}
+	if req.StartsAt != nil {
+		s.StartsAt = *req.StartsAt
+	}
+	if s.CreatedBy == "" {
+		s.CreatedBy = apiKeyID
+	}
+	if err := s.Validate(h.policy.Load().Levels); err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.store.Create(r.Context(), s); err != nil {
+		h.logger.Error("failed to create silence", slog.String("error", err.Error()))
+		WriteError(w, http.StatusInternalServerError, "failed to create silence")
+		return
+	}
+
+	h.logger.Info("silence created",
+		slog.String("api_key_id", apiKeyID),
+		slog.String("silence_id", s.ID),
+		slog.String("created_by", s.CreatedBy),
+		slog.Time("starts_at", s.StartsAt),
+		slog.Time("ends_at", s.EndsAt),
+	)
+	WriteJSON(w, http.StatusCreated, silenceResponse{Silence: s, State: s.State(time.Now())})
+}
+
+// HandleExpire handles DELETE /admin/silences/{id}. The silence ends now but
+// stays listed as expired for a day.
+func (h *SilenceHandler) HandleExpire(w http.ResponseWriter, r *http.Request) {
+	s, err := h.store.Expire(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, silence.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		h.logger.Error("failed to expire silence", slog.String("error", err.Error()))
+		WriteError(w, http.StatusInternalServerError, "failed to expire silence")
+		return
+	}
+
+	h.logger.Info("silence expired",
+		slog.String("api_key_id", GetAPIKey(r.Context()).ID),
+		slog.String("silence_id", s.ID),
+	)
+	WriteJSON(w, http.StatusOK, silenceResponse{Silence: s, State: s.State(time.Now())})
+}
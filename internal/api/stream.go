@@ -0,0 +1,185 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/luytbq/personal-notification-service/internal/notification"
+	"github.com/luytbq/personal-notification-service/internal/stream"
+)
+
+// Stream timings
+const (
+	streamKeepAlive    = 15 * time.Second // SSE comment / WebSocket ping interval
+	streamWriteTimeout = 10 * time.Second // per WebSocket message
+	sseRetryMillis     = 5000             // client reconnect delay
+)
+
+// StreamHandler pushes notifications and delivery status changes to clients
+// as they happen
+type StreamHandler struct {
+	hub    *stream.Hub
+	policy *PolicyHolder
+	logger *slog.Logger
+}
+
+// NewStreamHandler creates a new StreamHandler
+func NewStreamHandler(hub *stream.Hub, policy *PolicyHolder, logger *slog.Logger) *StreamHandler {
+	return &StreamHandler{
+		hub:    hub,
+		policy: policy,
+		logger: logger,
+	}
+}
+
+// HandleStream handles GET /notifications/stream. It speaks WebSocket when
+// the client asks for an upgrade and Server-Sent Events otherwise. Keys
+// without the admin scope only see notifications they sent.
+func (h *StreamHandler) HandleStream(w http.ResponseWriter, r *http.Request) {
+	apiKey := GetAPIKey(r.Context())
+	if apiKey == nil {
+		WriteError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	filter, err := parseStreamFilter(r, h.policy.Load().Levels)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !apiKey.Scopes.Admin {
+		filter.APIKeyID = apiKey.ID
+	}
+
+	// Streams outlive the server's read and write timeouts
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		WriteError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	_ = rc.SetReadDeadline(time.Time{})
+
+	sub := h.hub.Subscribe(filter)
+	defer sub.Close()
+
+	logger := h.logger.With(
+		slog.String("request_id", GetRequestID(r.Context())),
+		slog.String("api_key_id", apiKey.ID),
+	)
+
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		logger.Info("websocket stream opened")
+		err = h.serveWebSocket(w, r, sub)
+	} else {
+		logger.Info("event stream opened")
+		err = h.serveSSE(w, r, rc, sub)
+	}
+	if err != nil && r.Context().Err() == nil {
+		logger.Debug("stream closed", slog.String("error", err.Error()))
+		return
+	}
+	logger.Debug("stream closed")
+}
+
+// serveSSE writes events as Server-Sent Events until the client goes away
+func (h *StreamHandler) serveSSE(w http.ResponseWriter, r *http.Request, rc *http.ResponseController, sub *stream.Subscription) error {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // disable proxy buffering (nginx)
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", sseRetryMillis); err != nil {
+		return err
+	}
+	if err := rc.Flush(); err != nil {
+		return err
+	}
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return err
+			}
+		case e, ok := <-sub.Events():
+			if !ok {
+				return nil
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data); err != nil {
+				return err
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return err
+		}
+	}
+}
+
+// serveWebSocket writes events as JSON messages until the client goes away.
+// Messages from the client are ignored.
+func (h *StreamHandler) serveWebSocket(w http.ResponseWriter, r *http.Request, sub *stream.Subscription) error {
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		// Accept has already written the error response
+		return err
+	}
+	defer conn.CloseNow()
+
+	ctx := conn.CloseRead(r.Context())
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-keepAlive.C:
+			pingCtx, cancel := context.WithTimeout(ctx, streamWriteTimeout)
+			err := conn.Ping(pingCtx)
+			cancel()
+			if err != nil {
+				return err
+			}
+		case e, ok := <-sub.Events():
+			if !ok {
+				return conn.Close(websocket.StatusGoingAway, "server shutting down")
+			}
+			writeCtx, cancel := context.WithTimeout(ctx, streamWriteTimeout)
+			err := wsjson.Write(writeCtx, conn, e)
+			cancel()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// parseStreamFilter reads the filters of GET /notifications/stream
+func parseStreamFilter(r *http.Request, levels *notification.Levels) (stream.Filter, error) {
+	params := r.URL.Query()
+	f := stream.Filter{
+		Level:   notification.Level(params.Get("level")),
+		Source:  params.Get("source"),
+		Channel: notification.Channel(params.Get("channel")),
+	}
+	if f.Level != "" && !levels.Has(f.Level) {
+		return f, levels.InvalidError()
+	}
+	return f, nil
+}
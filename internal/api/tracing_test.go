@@ -0,0 +1,138 @@
+package api
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/luytbq/personal-notification-service/internal/channels"
+	"github.com/luytbq/personal-notification-service/internal/config"
+	"github.com/luytbq/personal-notification-service/internal/notification"
+	"github.com/luytbq/personal-notification-service/internal/queue"
+	"github.com/luytbq/personal-notification-service/internal/ratelimit"
+	"github.com/luytbq/personal-notification-service/internal/stream"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordingChannel accepts every notification and reports each one on sent
+type recordingChannel struct {
+	sent chan *notification.Notification
+}
+
+func (c *recordingChannel) Name() notification.Channel {
+	return notification.ChannelTelegram
+}
+
+func (c *recordingChannel) Send(_ context.Context, n *notification.Notification) error {
+	c.sent <- n
+	return nil
+}
+
+// TestTraceSpansRequestAndDelivery checks that the spans of the request and
+// of the worker's delivery belong to one trace, carried in the task payload
+func TestTraceSpansRequestAndDelivery(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+
+	redis := miniredis.RunT(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	names := queue.NewQueueNames("pns")
+	hub := stream.NewHub(redis.Addr(), "", 0, "pns", logger)
+
+	ch := &recordingChannel{sent: make(chan *notification.Notification, 1)}
+	registry := channels.NewRegistry()
+	registry.Register(ch)
+
+	policy, err := NewPolicy(&config.Config{}, registry)
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+	var holder PolicyHolder
+	holder.Store(policy)
+
+	client := queue.NewClient(redis.Addr(), "", 0, 0, logger, names, policy.Groups, nil, hub)
+	defer client.Close()
+	worker := queue.NewWorker(redis.Addr(), "", 0, 1, registry, logger, logger, names, nil, hub,
+		notification.NewFormatters(notification.FormatOptions{}, nil),
+		queue.DigestOptions{GracePeriod: time.Minute, MaxDelay: time.Hour, MaxSize: 10},
+		nil,
+	)
+	if err := worker.Start(); err != nil {
+		t.Fatalf("worker.Start: %v", err)
+	}
+	defer worker.Shutdown()
+
+	handler := NewHandler(&holder, ratelimit.NewLimiter(60), client, nil, nil, nil, 64<<10, logger)
+	body := `{"title": "Backup failed", "message": "disk full", "level": "error", "channel": ["telegram"]}`
+	req := httptest.NewRequest(http.MethodPost, "/notify", strings.NewReader(body))
+	req = req.WithContext(context.WithValue(req.Context(), APIKeyContextKey, &config.APIKey{ID: "test"}))
+	rec := httptest.NewRecorder()
+
+	// The HTTP middleware would start the request span
+	ctx, root := otel.Tracer("test").Start(req.Context(), "POST /notify")
+	handler.HandleNotify(rec, req.WithContext(ctx))
+	root.End()
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("HandleNotify status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	select {
+	case <-ch.sent:
+	case <-time.After(10 * time.Second):
+		t.Fatal("notification was not delivered")
+	}
+
+	// The deliver span ends after Send returns
+	wantSpans := []string{"validate", "authorize", "rate_limit", "enqueue", "deliver telegram"}
+	var spans []sdktrace.ReadOnlySpan
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		spans = recorder.Ended()
+		if hasSpans(spans, wantSpans) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("missing spans, want %v among %v", wantSpans, spanNames(spans))
+		}
+	}
+
+	want := root.SpanContext().TraceID()
+	for _, s := range spans {
+		if got := s.SpanContext().TraceID(); got != want {
+			t.Errorf("span %q has trace %s, want %s", s.Name(), got, want)
+		}
+	}
+}
+
+// hasSpans reports whether every name is among the spans
+func hasSpans(spans []sdktrace.ReadOnlySpan, names []string) bool {
+	seen := make(map[string]bool, len(spans))
+	for _, s := range spans {
+		seen[s.Name()] = true
+	}
+	for _, name := range names {
+		if !seen[name] {
+			return false
+		}
+	}
+	return true
+}
+
+func spanNames(spans []sdktrace.ReadOnlySpan) []string {
+	names := make([]string, len(spans))
+	for i, s := range spans {
+		names[i] = s.Name()
+	}
+	return names
+}
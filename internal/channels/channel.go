@@ -2,8 +2,17 @@ package channels
 
 import (
 	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/luytbq/personal-notification-service/internal/notification"
+	"github.com/luytbq/personal-notification-service/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Channel defines the interface for notification channels
@@ -15,25 +24,143 @@ type Channel interface {
 	Send(ctx context.Context, n *notification.Notification) error
 }
 
-// Registry holds all registered notification channels
+type loggerKey struct{}
+
+// WithLogger returns a context whose logger channels use for delivery details
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// loggerFrom returns the logger set by WithLogger, or the default logger
+func loggerFrom(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+type formatterKey struct{}
+
+// WithFormatter returns a context whose formatter channels use to render
+// notifications
+func WithFormatter(ctx context.Context, f notification.Formatter) context.Context {
+	return context.WithValue(ctx, formatterKey{}, f)
+}
+
+// formatterFrom returns the formatter set by WithFormatter, or plain text
+// with the default options
+func formatterFrom(ctx context.Context) notification.Formatter {
+	if f, ok := ctx.Value(formatterKey{}).(notification.Formatter); ok {
+		return f
+	}
+	return notification.DefaultFormatter
+}
+
+var tracer = tracing.Tracer("channels")
+
+// startSendSpan starts the client span around a channel's outgoing HTTP call.
+// Only the host is recorded: URLs may carry secrets such as the bot token.
+func startSendSpan(ctx context.Context, name notification.Channel, n *notification.Notification, host string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "send "+string(name),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("notification.id", n.ID),
+			attribute.String("notification.channel", string(name)),
+			attribute.String("server.address", host),
+		),
+	)
+}
+
+// RedactURL cuts the URL of an HTTP request error in err down to its scheme
+// and host, like the send span. Errors of net/http quote the full URL, which
+// may carry secrets such as the bot token. err keeps its wrapped errors.
+func RedactURL(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+	full := urlErr.URL
+	if u, perr := url.Parse(full); perr == nil && u.Host != "" {
+		urlErr.URL = u.Scheme + "://" + u.Host
+	} else {
+		urlErr.URL = "[redacted]"
+	}
+	if urlErr.URL == full {
+		return err
+	}
+	// Errors that wrapped urlErr already hold its text with the full URL
+	return &redactedError{msg: strings.ReplaceAll(err.Error(), full, urlErr.URL), err: err}
+}
+
+// redactedError replaces the text of err
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+// Unwrap keeps errors.Is checks, e.g. for asynq.SkipRetry, working
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// Registry holds all registered notification channels.
+// It is safe for concurrent use, so channels can be added or removed at runtime.
 type Registry struct {
+	mu       sync.RWMutex
 	channels map[notification.Channel]Channel
+	health   map[notification.Channel]*Health
 }
 
 // NewRegistry creates a new channel registry
 func NewRegistry() *Registry {
 	return &Registry{
 		channels: make(map[notification.Channel]Channel),
+		health:   make(map[notification.Channel]*Health),
 	}
 }
 
-// Register registers a channel
+// Register registers a channel, replacing any channel with the same name
 func (r *Registry) Register(ch Channel) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.channels[ch.Name()] = ch
 }
 
+// Unregister removes a channel by name
+func (r *Registry) Unregister(name notification.Channel) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.channels, name)
+	delete(r.health, name)
+}
+
 // Get returns a channel by name
 func (r *Registry) Get(name notification.Channel) (Channel, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	ch, ok := r.channels[name]
 	return ch, ok
 }
+
+// Has reports whether a channel is registered under name
+func (r *Registry) Has(name notification.Channel) bool {
+	_, ok := r.Get(name)
+	return ok
+}
+
+// Names returns the sorted names of all registered channels
+func (r *Registry) Names() []notification.Channel {
+	r.mu.RLock()
+	names := make([]notification.Channel, 0, len(r.channels))
+	for name := range r.channels {
+		names = append(names, name)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
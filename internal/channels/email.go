@@ -35,8 +35,8 @@ func (e *EmailChannel) Name() notification.Channel {
 func (e *EmailChannel) Send(ctx context.Context, n *notification.Notification) error {
 	// TODO: Implement email sending
 	// Example implementation:
-	// 1. Format email with subject: "[LEVEL] Title" and body: Message
-	// 2. Append source if provided: "Source: <source>"
+	// 1. Render the body with formatterFrom(ctx)
+	// 2. Use "[LEVEL] Title" as the subject
 	// 3. Connect to SMTP server
 	// 4. Send email
 	return ErrEmailNotImplemented
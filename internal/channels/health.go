@@ -0,0 +1,71 @@
+package channels
+
+import (
+	"time"
+
+	"github.com/luytbq/personal-notification-service/internal/notification"
+)
+
+// Channel health states
+const (
+	HealthUnknown = "unknown" // no delivery since startup
+	HealthOK      = "ok"      // last delivery succeeded
+	HealthFailing = "failing" // last delivery failed
+)
+
+// Health describes recent delivery results of one channel in this process
+type Health struct {
+	Channel             notification.Channel `json:"channel"`
+	Status              string               `json:"status"`
+	Sent                int64                `json:"sent"`
+	Failed              int64                `json:"failed"`
+	ConsecutiveFailures int                  `json:"consecutive_failures"`
+	LastSuccess         *time.Time           `json:"last_success,omitempty"`
+	LastFailure         *time.Time           `json:"last_failure,omitempty"`
+	LastError           string               `json:"last_error,omitempty"`
+}
+
+// RecordResult records the outcome of a delivery attempt through a channel.
+// The error is served by the health endpoint, so request URLs are cut from it.
+func (r *Registry) RecordResult(name notification.Channel, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	h, ok := r.health[name]
+	if !ok {
+		h = &Health{Channel: name}
+		r.health[name] = h
+	}
+
+	now := time.Now()
+	if err != nil {
+		h.Status = HealthFailing
+		h.Failed++
+		h.ConsecutiveFailures++
+		h.LastFailure = &now
+		h.LastError = RedactURL(err).Error()
+		return
+	}
+	h.Status = HealthOK
+	h.Sent++
+	h.ConsecutiveFailures = 0
+	h.LastSuccess = &now
+}
+
+// Health returns the health of every registered channel, sorted by name
+func (r *Registry) Health() []Health {
+	names := r.Names()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	health := make([]Health, 0, len(names))
+	for _, name := range names {
+		if h, ok := r.health[name]; ok {
+			health = append(health, *h)
+		} else {
+			health = append(health, Health{Channel: name, Status: HealthUnknown})
+		}
+	}
+	return health
+}
@@ -0,0 +1,36 @@
+package channels
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/luytbq/personal-notification-service/internal/notification"
+)
+
+func TestRecordResultRedactsURL(t *testing.T) {
+	const token = "123456:very-secret-token"
+	r := NewRegistry()
+	r.Register(NewTelegramChannel(token, "42"))
+
+	err := fmt.Errorf("failed to send telegram message: %w", &url.Error{
+		Op:  "Post",
+		URL: "https://api.telegram.org/bot" + token + "/sendMessage",
+		Err: errors.New("connection refused"),
+	})
+	r.RecordResult(notification.ChannelTelegram, err)
+
+	health := r.Health()
+	if len(health) != 1 {
+		t.Fatalf("got %d channels, want 1", len(health))
+	}
+	h := health[0]
+	if h.Status != HealthFailing || h.Failed != 1 {
+		t.Errorf("status = %s with %d failed, want %s with 1", h.Status, h.Failed, HealthFailing)
+	}
+	want := `failed to send telegram message: Post "https://api.telegram.org": connection refused`
+	if h.LastError != want {
+		t.Errorf("LastError = %q, want %q", h.LastError, want)
+	}
+}
@@ -0,0 +1,174 @@
+package channels
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luytbq/personal-notification-service/internal/notification"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// assertGolden compares got with testdata/<name>.golden, or rewrites the file
+// when the test runs with -update
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("failed to update %s: %v", path, err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v (run with -update to create it)", path, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s does not match\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// captureTransport records the body of each request and answers with reply
+type captureTransport struct {
+	reply string
+	req   *http.Request
+	body  []byte
+}
+
+func (c *captureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.req, c.body = req, body
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(c.reply)),
+		Request:    req,
+	}, nil
+}
+
+// indented returns a JSON body indented for reading as a golden file
+func indented(t *testing.T, body []byte) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	if err := json.Indent(&b, body, "", "  "); err != nil {
+		t.Fatalf("body is not JSON: %v\n%s", err, body)
+	}
+	b.WriteByte('\n')
+	return b.Bytes()
+}
+
+func testNotification() *notification.Notification {
+	return &notification.Notification{
+		ID:        "3f0c2a9e-6a1d-4a43-9d55-0b8a6b1f1c2e",
+		Title:     "Backup failed (nightly_db)",
+		Message:   "Disk /var is 98% full; see https://ops.example.com/runbook?id=7 & retry.",
+		Level:     notification.LevelError,
+		Channel:   notification.ChannelTelegram,
+		APIKeyID:  "ops",
+		CreatedAt: time.Date(2026, 3, 14, 9, 26, 53, 0, time.UTC),
+		Source:    "backup-job",
+		Tags:      []string{"db", "nightly"},
+		RequestID: "req-42",
+	}
+}
+
+func TestTelegramPayloadGolden(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   notification.FormatOptions
+		modify func(n *notification.Notification)
+	}{
+		{name: "text", opts: notification.FormatOptions{Markup: notification.MarkupText, Location: time.UTC, ShowSource: true}},
+		{name: "markdown", opts: notification.FormatOptions{Markup: notification.MarkupMarkdown, Location: time.UTC, ShowSource: true}},
+		{name: "html", opts: notification.FormatOptions{Markup: notification.MarkupHTML, Location: time.UTC, ShowSource: true}},
+		{
+			name: "silent_recipient",
+			opts: notification.FormatOptions{Markup: notification.MarkupMarkdown, Location: time.UTC},
+			modify: func(n *notification.Notification) {
+				n.Silent = true
+				n.Recipient = "-100200300"
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &captureTransport{reply: `{"ok": true}`}
+			ch := NewTelegramChannel("123:secret", "42")
+			ch.client.Transport = transport
+
+			n := testNotification()
+			if tt.modify != nil {
+				tt.modify(n)
+			}
+			ctx := WithFormatter(context.Background(), notification.NewFormatter(tt.opts))
+			if err := ch.Send(ctx, n); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			assertGolden(t, "telegram_"+tt.name, indented(t, transport.body))
+		})
+	}
+}
+
+func TestWebhookPayloadGolden(t *testing.T) {
+	levels, err := notification.NewLevels([]notification.LevelDef{
+		{Name: notification.LevelInfo, Severity: 20, Prefix: "[INFO]", Priority: notification.PriorityNormal},
+		{Name: notification.LevelWarning, Severity: 30, Prefix: "[WARNING]", Priority: notification.PriorityNormal},
+		{Name: notification.LevelError, Severity: 45, Prefix: "[ERR]", Emoji: "🔴", Color: "#ff0000", Priority: notification.PriorityHigh},
+		{Name: notification.LevelCritical, Severity: 50, Prefix: "[CRITICAL]", Priority: notification.PriorityHigh},
+	})
+	if err != nil {
+		t.Fatalf("NewLevels: %v", err)
+	}
+	saigon, err := time.LoadLocation("Asia/Ho_Chi_Minh")
+	if err != nil {
+		t.Skipf("time zone Asia/Ho_Chi_Minh is not available: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		opts notification.FormatOptions
+	}{
+		{name: "text", opts: notification.FormatOptions{Markup: notification.MarkupText, Location: time.UTC, ShowSource: true}},
+		{
+			name: "custom_levels",
+			opts: notification.FormatOptions{
+				Markup: notification.MarkupMarkdown, Location: saigon, Levels: levels,
+				TimestampFormat: "02/01/2006 15:04", Locale: notification.LocaleVietnamese,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &captureTransport{}
+			ch := NewWebhookChannel("notifeed", "https://hooks.example.com/pns", "s3cret")
+			ch.client.Transport = transport
+
+			n := testNotification()
+			n.Channel = ch.Name()
+			ctx := WithFormatter(context.Background(), notification.NewFormatter(tt.opts))
+			if err := ch.Send(ctx, n); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			if got, want := transport.req.Header.Get("X-PNS-Signature"), "sha256="+computeHMAC(transport.body, "s3cret"); got != want {
+				t.Errorf("X-PNS-Signature = %q, want %q", got, want)
+			}
+			if got := transport.req.Header.Get("X-Request-ID"); got != "req-42" {
+				t.Errorf("X-Request-ID = %q, want req-42", got)
+			}
+			assertGolden(t, "webhook_"+tt.name, indented(t, transport.body))
+		})
+	}
+}
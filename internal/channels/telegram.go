@@ -6,26 +6,41 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/luytbq/personal-notification-service/internal/notification"
+	"github.com/luytbq/personal-notification-service/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
-	telegramAPIURL = "https://api.telegram.org/bot%s/sendMessage"
+	telegramAPIHost = "api.telegram.org"
+	telegramAPIURL  = "https://" + telegramAPIHost + "/bot%s/sendMessage"
 )
 
 // TelegramChannel sends notifications via Telegram Bot API
 type TelegramChannel struct {
+	name     notification.Channel
 	botToken string
 	chatID   string
 	client   *http.Client
 }
 
-// NewTelegramChannel creates a new Telegram channel
+// NewTelegramChannel creates the default "telegram" channel
 func NewTelegramChannel(botToken, chatID string) *TelegramChannel {
+	return newTelegramChannel(notification.ChannelTelegram, botToken, chatID)
+}
+
+// NewNamedTelegramChannel creates a Telegram target named "telegram:<name>"
+func NewNamedTelegramChannel(name, botToken, chatID string) *TelegramChannel {
+	return newTelegramChannel(notification.Channel(notification.ChannelTelegramPrefix+name), botToken, chatID)
+}
+
+func newTelegramChannel(name notification.Channel, botToken, chatID string) *TelegramChannel {
 	return &TelegramChannel{
+		name:     name,
 		botToken: botToken,
 		chatID:   chatID,
 		client: &http.Client{
@@ -34,16 +49,23 @@ func NewTelegramChannel(botToken, chatID string) *TelegramChannel {
 	}
 }
 
-// Name returns the channel name
+// Name returns the channel name (e.g. "telegram" or "telegram:ops")
 func (t *TelegramChannel) Name() notification.Channel {
-	return notification.ChannelTelegram
+	return t.name
 }
 
 // telegramMessage represents the Telegram sendMessage request
 type telegramMessage struct {
-	ChatID    string `json:"chat_id"`
-	Text      string `json:"text"`
-	ParseMode string `json:"parse_mode,omitempty"`
+	ChatID              string `json:"chat_id"`
+	Text                string `json:"text"`
+	ParseMode           string `json:"parse_mode,omitempty"`
+	DisableNotification bool   `json:"disable_notification,omitempty"`
+}
+
+// telegramParseModes maps markups to Telegram parse modes; plain text has none
+var telegramParseModes = map[notification.Markup]string{
+	notification.MarkupMarkdown: "MarkdownV2",
+	notification.MarkupHTML:     "HTML",
 }
 
 // telegramResponse represents the Telegram API response
@@ -54,24 +76,30 @@ type telegramResponse struct {
 }
 
 // Send sends a notification via Telegram
-func (t *TelegramChannel) Send(ctx context.Context, n *notification.Notification) error {
-	// Format message with level prefix
-	// TODO: Try other parse modes (Markdown, HTML) for richer formatting
-	text := fmt.Sprintf("%s %s\n\n%s", n.Level.Prefix(), n.Title, n.Message)
-
-	// Append source if provided
-	if n.Source != "" {
-		text = fmt.Sprintf("%s\n\nSource: %s", text, n.Source)
+func (t *TelegramChannel) Send(ctx context.Context, n *notification.Notification) (err error) {
+	ctx, span := startSendSpan(ctx, t.name, n, telegramAPIHost)
+	defer func() {
+		if err != nil {
+			tracing.Fail(span, err)
+		}
+		span.End()
+	}()
+
+	formatter := formatterFrom(ctx)
+	text := formatter.Render(n)
+
+	// Use the per-request recipient override when present
+	chatID := t.chatID
+	if n.Recipient != "" {
+		chatID = n.Recipient
 	}
 
-	// Append timestamp
-	timestamp := n.CreatedAt.Format("2006-01-02 15:04:05")
-	text = fmt.Sprintf("%s\nTimestamp: %s", text, timestamp)
-
 	msg := telegramMessage{
-		ChatID: t.chatID,
-		Text:   text,
-		// ParseMode left empty for plain text
+		ChatID:    chatID,
+		Text:      text,
+		ParseMode: telegramParseModes[formatter.Markup()],
+		// Silent during quiet hours
+		DisableNotification: n.Silent,
 	}
 
 	body, err := json.Marshal(msg)
@@ -82,13 +110,19 @@ func (t *TelegramChannel) Send(ctx context.Context, n *notification.Notification
 	url := fmt.Sprintf(telegramAPIURL, t.botToken)
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return fmt.Errorf("failed to create request: %w", RedactURL(err))
 	}
 	req.Header.Set("Content-Type", "application/json")
 
+	logger := loggerFrom(ctx).With(
+		slog.String("notification_id", n.ID),
+		slog.String("channel", string(t.name)),
+	)
+	logger.Debug("sending telegram message", slog.String("chat_id", chatID), slog.Int("bytes", len(body)))
+
 	resp, err := t.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send telegram message: %w", err)
+		return fmt.Errorf("failed to send telegram message: %w", RedactURL(err))
 	}
 	defer resp.Body.Close()
 
@@ -102,6 +136,13 @@ func (t *TelegramChannel) Send(ctx context.Context, n *notification.Notification
 		return fmt.Errorf("failed to parse telegram response: %w", err)
 	}
 
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	logger.Debug("telegram API responded",
+		slog.Int("status", resp.StatusCode),
+		slog.Bool("ok", telegramResp.OK),
+		slog.String("description", telegramResp.Description),
+	)
+
 	if !telegramResp.OK {
 		return fmt.Errorf("telegram API error: %s (code: %d)", telegramResp.Description, telegramResp.ErrorCode)
 	}
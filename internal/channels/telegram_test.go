@@ -0,0 +1,65 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// failingTransport fails every request with err
+type failingTransport struct {
+	err error
+}
+
+func (f failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, f.err
+}
+
+// TestTelegramSendErrorHidesToken checks that a network failure reports the
+// host but not the bot token, in the error and in the span
+func TestTelegramSendErrorHidesToken(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+
+	const token = "123456:very-secret-token"
+	errRefused := errors.New("connection refused")
+	ch := NewTelegramChannel(token, "42")
+	ch.client.Transport = failingTransport{err: errRefused}
+
+	err := ch.Send(context.Background(), testNotification())
+	if err == nil {
+		t.Fatal("Send succeeded, want an error")
+	}
+	if strings.Contains(err.Error(), token) {
+		t.Errorf("error contains the bot token: %v", err)
+	}
+	if !strings.Contains(err.Error(), telegramAPIHost) {
+		t.Errorf("error %q does not name the host %s", err, telegramAPIHost)
+	}
+	if !errors.Is(err, errRefused) {
+		t.Errorf("error %v does not wrap the transport error", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	if desc := spans[0].Status().Description; strings.Contains(desc, token) {
+		t.Errorf("span status contains the bot token: %s", desc)
+	}
+	for _, ev := range spans[0].Events() {
+		for _, attr := range ev.Attributes {
+			if strings.Contains(attr.Value.Emit(), token) {
+				t.Errorf("span event %s contains the bot token: %s", ev.Name, attr.Value.Emit())
+			}
+		}
+	}
+}
@@ -8,16 +8,21 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
+	neturl "net/url"
 	"time"
 
 	"github.com/luytbq/personal-notification-service/internal/notification"
+	"github.com/luytbq/personal-notification-service/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // WebhookChannel sends notifications via HTTP POST with HMAC-SHA256 signature
 type WebhookChannel struct {
 	name   notification.Channel
 	url    string
+	host   string
 	secret string
 	client *http.Client
 }
@@ -27,6 +32,7 @@ func NewWebhookChannel(name, url, secret string) *WebhookChannel {
 	return &WebhookChannel{
 		name:   notification.Channel(notification.ChannelWebhookPrefix + name),
 		url:    url,
+		host:   hostOf(url),
 		secret: secret,
 		client: &http.Client{Timeout: 30 * time.Second},
 	}
@@ -37,26 +43,66 @@ func (w *WebhookChannel) Name() notification.Channel {
 	return w.name
 }
 
+// webhookPayload is the notification plus its rendered text, for receivers
+// that only display it, and the level's severity and color
+type webhookPayload struct {
+	*notification.Notification
+	Text     string              `json:"text"`
+	Markup   notification.Markup `json:"markup"`
+	Severity int                 `json:"severity"`
+	Color    string              `json:"color,omitempty"`
+}
+
 // Send POSTs the notification as JSON with an HMAC-SHA256 signature header
-func (w *WebhookChannel) Send(ctx context.Context, n *notification.Notification) error {
-	body, err := json.Marshal(n)
+func (w *WebhookChannel) Send(ctx context.Context, n *notification.Notification) (err error) {
+	ctx, span := startSendSpan(ctx, w.name, n, w.host)
+	defer func() {
+		if err != nil {
+			tracing.Fail(span, err)
+		}
+		span.End()
+	}()
+
+	formatter := formatterFrom(ctx)
+	level := formatter.Level(n.Level)
+	body, err := json.Marshal(webhookPayload{
+		Notification: n,
+		Text:         formatter.Render(n),
+		Markup:       formatter.Markup(),
+		Severity:     level.Severity,
+		Color:        level.Color,
+	})
 	if err != nil {
 		return fmt.Errorf("failed to marshal notification: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
 	if err != nil {
-		return fmt.Errorf("failed to create webhook request: %w", err)
+		return fmt.Errorf("failed to create webhook request: %w", RedactURL(err))
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-PNS-Signature", "sha256="+computeHMAC(body, w.secret))
+	if n.RequestID != "" {
+		req.Header.Set("X-Request-ID", n.RequestID)
+	}
+	// Let receivers that are traced themselves join the trace
+	tracing.InjectHTTP(ctx, req.Header)
+
+	logger := loggerFrom(ctx).With(
+		slog.String("notification_id", n.ID),
+		slog.String("channel", string(w.name)),
+	)
+	logger.Debug("sending webhook", slog.String("url", w.url), slog.Int("bytes", len(body)))
 
 	resp, err := w.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("webhook request failed: %w", err)
+		return fmt.Errorf("webhook request failed: %w", RedactURL(err))
 	}
 	defer resp.Body.Close()
 
+	logger.Debug("webhook responded", slog.Int("status", resp.StatusCode))
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		return fmt.Errorf("webhook returned non-2xx status: %d", resp.StatusCode)
 	}
@@ -64,6 +110,15 @@ func (w *WebhookChannel) Send(ctx context.Context, n *notification.Notification)
 	return nil
 }
 
+// hostOf returns the host of a URL for tracing, without credentials or path
+func hostOf(rawURL string) string {
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
 func computeHMAC(body []byte, secret string) string {
 	mac := hmac.New(sha256.New, []byte(secret))
 	mac.Write(body)
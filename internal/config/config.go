@@ -1,16 +1,30 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"reflect"
+	"strings"
+	"time"
 
+	"github.com/luytbq/personal-notification-service/internal/logging"
+	"github.com/luytbq/personal-notification-service/internal/notification"
 	"gopkg.in/yaml.v3"
 )
 
 type ServerConfig struct {
-	Port                   int    `yaml:"port"`
-	LogLevel               string `yaml:"log_level"`
-	ShutdownTimeoutSeconds int    `yaml:"shutdown_timeout_seconds"`
+	Port                   int               `yaml:"port"`
+	LogLevel               string            `yaml:"log_level"`
+	LogFormat              string            `yaml:"log_format"` // json or text
+	LogOutput              string            `yaml:"log_output"` // stdout, stderr or a file path
+	LogLevels              map[string]string `yaml:"log_levels"` // per component: api, worker, channels
+	ShutdownTimeoutSeconds int               `yaml:"shutdown_timeout_seconds"`
+	MaxRequestBytes        int64             `yaml:"max_request_bytes"`
+	WatchConfig            bool              `yaml:"watch_config"` // reload when the file changes
 }
 
 type RedisConfig struct {
@@ -25,6 +39,48 @@ type WorkerConfig struct {
 	MaxRetries  int `yaml:"max_retries"`
 }
 
+// TracingConfig configures OpenTelemetry tracing, exported over OTLP/HTTP
+type TracingConfig struct {
+	Enabled     bool    `yaml:"enabled"`
+	Endpoint    string  `yaml:"endpoint"`     // collector host:port
+	Insecure    bool    `yaml:"insecure"`     // plain HTTP instead of HTTPS
+	SampleRatio float64 `yaml:"sample_ratio"` // fraction of new traces to record, 0 to 1
+	ServiceName string  `yaml:"service_name"`
+}
+
+// HistoryConfig configures the persistent notification history
+type HistoryConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	Driver        string `yaml:"driver"`         // only "sqlite" for now
+	Path          string `yaml:"path"`           // database file
+	RetentionDays int    `yaml:"retention_days"` // 0 keeps history forever
+}
+
+// DedupConfig suppresses repeats of the same notification within a window
+type DedupConfig struct {
+	Enabled       bool     `yaml:"enabled"`
+	WindowSeconds int      `yaml:"window_seconds"`
+	Fields        []string `yaml:"fields"` // fingerprint fields when the request has no fingerprint
+}
+
+// FloodConfig mutes a source that sends too many distinct notifications.
+// Notifications are told apart by fingerprint, as configured for dedup.
+type FloodConfig struct {
+	Enabled         bool   `yaml:"enabled"`
+	Threshold       int    `yaml:"threshold"` // notifications within the window that mute the source
+	WindowSeconds   int    `yaml:"window_seconds"`
+	CooldownSeconds int    `yaml:"cooldown_seconds"` // how long the source stays muted
+	BypassLevel     string `yaml:"bypass_level"`     // this level and worse are never muted; empty mutes every level
+}
+
+// DigestConfig controls when notifications for digest channels are sent as
+// one summary
+type DigestConfig struct {
+	GracePeriodSeconds int `yaml:"grace_period_seconds"` // send once nothing new arrived for this long
+	MaxDelaySeconds    int `yaml:"max_delay_seconds"`    // send at the latest this long after the first
+	MaxSize            int `yaml:"max_size"`             // send once this many are pending
+}
+
 type TelegramConfig struct {
 	BotToken string `yaml:"bot_token"`
 	ChatID   string `yaml:"chat_id"`
@@ -36,36 +92,200 @@ type WebhookTarget struct {
 	Secret string `yaml:"secret"`
 }
 
+// APIKeyScopes restricts what an API key may send. Empty fields are unrestricted.
+type APIKeyScopes struct {
+	Channels    []string `yaml:"channels" json:"channels,omitempty"`         // concrete channels or group:<name>
+	Levels      []string `yaml:"levels" json:"levels,omitempty"`             // allowed levels
+	MaxPriority string   `yaml:"max_priority" json:"max_priority,omitempty"` // highest allowed priority
+	Recipients  []string `yaml:"recipients" json:"recipients,omitempty"`     // allowed recipient overrides
+	Admin       bool     `yaml:"admin" json:"admin,omitempty"`               // access to the /admin API
+}
+
+// APIKey is a named API key identity. Only ID travels with notifications.
+// The secret presented in the X-API-Key header is stored as KeyHash,
+// "sha256:<hex>"; a plaintext Key is hashed at load time and then discarded.
+type APIKey struct {
+	ID        string       `yaml:"id" json:"id"`
+	Name      string       `yaml:"name" json:"name"`
+	Owner     string       `yaml:"owner" json:"owner"`
+	Key       string       `yaml:"key" json:"-"`
+	KeyHash   string       `yaml:"key_hash" json:"key_hash"`
+	ExpiresAt time.Time    `yaml:"expires_at" json:"expires_at"`
+	Scopes    APIKeyScopes `yaml:"scopes" json:"scopes"`
+}
+
+// Expired reports whether the key has an expiry date that has passed
+func (k *APIKey) Expired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && now.After(k.ExpiresAt)
+}
+
+// UnmarshalYAML accepts either a full key mapping or, for backwards
+// compatibility, a plain key string whose ID is derived from its hash.
+func (k *APIKey) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		k.Key = value.Value
+		return nil
+	}
+	type plain APIKey
+	return value.Decode((*plain)(k))
+}
+
+// HashAPIKey returns the hex-encoded SHA-256 digest of a raw key
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// DeriveAPIKeyID returns a stable, non-secret identifier from a key hash
+func DeriveAPIKeyID(hash string) string {
+	return "key-" + hash[:8]
+}
+
+// parseKeyHash normalizes a "sha256:<hex>" key hash to its lowercase hex digest
+func parseKeyHash(s string) (string, error) {
+	digest, ok := strings.CutPrefix(strings.TrimSpace(s), "sha256:")
+	if !ok {
+		return "", fmt.Errorf("key_hash must have the form sha256:<hex>")
+	}
+	digest = strings.ToLower(digest)
+	if b, err := hex.DecodeString(digest); err != nil || len(b) != sha256.Size {
+		return "", fmt.Errorf("key_hash must contain a 64 character hex SHA-256 digest")
+	}
+	return digest, nil
+}
+
+// Validate checks scope values against the configured levels
+func (s APIKeyScopes) Validate(levels *notification.Levels) error {
+	for _, l := range s.Levels {
+		if !levels.Has(notification.Level(l)) {
+			return fmt.Errorf("scopes.levels: invalid level %q", l)
+		}
+	}
+	if s.MaxPriority != "" && !notification.Priority(s.MaxPriority).IsValid() {
+		return fmt.Errorf("scopes.max_priority: invalid priority %q", s.MaxPriority)
+	}
+	return nil
+}
+
+// RouteMatch holds the conditions of a routing rule.
+// Empty fields match anything; all non-empty fields must match.
+type RouteMatch struct {
+	Levels      []string `yaml:"levels"`
+	MinLevel    string   `yaml:"min_level"`    // this level or any more severe one
+	Source      string   `yaml:"source"`       // glob, e.g. "backup-*"
+	SourceRegex string   `yaml:"source_regex"` // regular expression
+	TitleRegex  string   `yaml:"title_regex"`  // regular expression
+	Tags        []string `yaml:"tags"`         // request must carry all listed tags
+	APIKeys     []string `yaml:"api_keys"`     // API key IDs
+}
+
+// RouteRule maps matching notifications to a set of channels.
+// Evaluation stops at the first matching rule unless Continue is set.
+type RouteRule struct {
+	Name     string     `yaml:"name"`
+	Match    RouteMatch `yaml:"match"`
+	Channels []string   `yaml:"channels"`
+	Continue bool       `yaml:"continue"`
+	Digest   bool       `yaml:"digest"` // batch into digests on the rule's channels
+}
+
+// RoutingConfig holds the rules used for requests that do not list channels
+type RoutingConfig struct {
+	Rules    []RouteRule `yaml:"rules"`
+	Fallback []string    `yaml:"fallback"` // used when no rule matches
+}
+
+// QuietHoursConfig holds back non-urgent notifications during the given
+// daily windows, e.g. "23:00-07:00"
+type QuietHoursConfig struct {
+	Windows     []string `yaml:"windows"`
+	Timezone    string   `yaml:"timezone"`     // IANA name, "UTC" or "Local" (default)
+	Mode        string   `yaml:"mode"`         // hold (default) or silent
+	BypassLevel string   `yaml:"bypass_level"` // this level and worse are never held; default critical
+}
+
+// ChannelFilterConfig limits what a channel or target receives, and when
+type ChannelFilterConfig struct {
+	MinLevel   string           `yaml:"min_level"`
+	QuietHours QuietHoursConfig `yaml:"quiet_hours"`
+	Digest     bool             `yaml:"digest"` // batch notifications into digests
+}
+
+// TemplateContent is a Go text/template title and message
+type TemplateContent struct {
+	Title   string `yaml:"title"`
+	Message string `yaml:"message"`
+}
+
+// TemplateConfig defines a named message template. Level and channels are
+// defaults the request may override; overrides replace the title and/or
+// message for individual channels.
+type TemplateConfig struct {
+	TemplateContent `yaml:",inline"`
+	Level           string                     `yaml:"level"`
+	Channels        []string                   `yaml:"channels"`
+	Required        []string                   `yaml:"required"` // variables the request must provide
+	Overrides       map[string]TemplateContent `yaml:"overrides"`
+}
+
 // Config holds all application configuration
 type Config struct {
-	Server             ServerConfig   `yaml:"server"`
-	APIKeys            []string       `yaml:"api_keys"`
-	RateLimitPerMinute int            `yaml:"rate_limit_per_minute"`
-	Redis              RedisConfig    `yaml:"redis"`
-	Worker             WorkerConfig   `yaml:"worker"`
-	Telegram           TelegramConfig `yaml:"telegram"`
-	Webhooks           []WebhookTarget `yaml:"webhooks"`
-	apiKeysMap         map[string]bool
-}
-
-// Load reads configuration from a YAML file.
-// Path is taken from PNS_CONFIG env var, defaulting to config.yaml.
-func Load() (*Config, error) {
-	path := os.Getenv("PNS_CONFIG")
-	if path == "" {
-		path = "config.yaml"
+	Server             ServerConfig                   `yaml:"server"`
+	APIKeys            []APIKey                       `yaml:"api_keys"`
+	RateLimitPerMinute int                            `yaml:"rate_limit_per_minute"`
+	Redis              RedisConfig                    `yaml:"redis"`
+	Worker             WorkerConfig                   `yaml:"worker"`
+	Tracing            TracingConfig                  `yaml:"tracing"`
+	History            HistoryConfig                  `yaml:"history"`
+	Dedup              DedupConfig                    `yaml:"dedup"`
+	Flood              FloodConfig                    `yaml:"flood"`
+	Digest             DigestConfig                   `yaml:"digest"`
+	Telegram           TelegramConfig                 `yaml:"telegram"`
+	Webhooks           []WebhookTarget                `yaml:"webhooks"`
+	Levels             LevelsConfig                   `yaml:"levels"`
+	Formatting         FormattingConfig               `yaml:"formatting"`
+	Routing            RoutingConfig                  `yaml:"routing"`
+	ChannelGroups      map[string][]string            `yaml:"channel_groups"`
+	ChannelFilters     map[string]ChannelFilterConfig `yaml:"channel_filters"`
+	Templates          map[string]TemplateConfig      `yaml:"templates"`
+	apiKeysMap         map[string]*APIKey
+}
+
+// Path returns the config file path, taken from the PNS_CONFIG env var
+// and defaulting to config.yaml
+func Path() string {
+	if path := os.Getenv("PNS_CONFIG"); path != "" {
+		return path
 	}
+	return "config.yaml"
+}
+
+// Load reads and validates configuration from the YAML file at Path.
+// ${VAR} references in the file are expanded, <key>_file entries are replaced
+// by the contents of the named file, and PNS_* environment variables override
+// the result. Without PNS_CONFIG a missing config.yaml is not an error, so the
+// service can be configured from the environment alone.
+// It is also used for reloads, so it must not have side effects.
+func Load() (*Config, error) {
+	path := Path()
 
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config file %q: %w", path, err)
+		_, explicit := os.LookupEnv("PNS_CONFIG")
+		if explicit || !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read config file %q: %w", path, err)
+		}
+		data = nil
 	}
 
 	cfg := &Config{
 		Server: ServerConfig{
 			Port:                   8272,
 			LogLevel:               "info",
+			LogFormat:              logging.FormatJSON,
+			LogOutput:              "stdout",
 			ShutdownTimeoutSeconds: 30,
+			MaxRequestBytes:        64 << 10,
 		},
 		RateLimitPerMinute: 60,
 		Redis: RedisConfig{
@@ -77,11 +297,128 @@ func Load() (*Config, error) {
 			Concurrency: 10,
 			MaxRetries:  5,
 		},
+		Tracing: TracingConfig{
+			Endpoint:    "localhost:4318",
+			SampleRatio: 1,
+			ServiceName: "pns",
+		},
+		History: HistoryConfig{
+			Enabled: true,
+			Driver:  "sqlite",
+			Path:    "history.db",
+		},
+		Dedup: DedupConfig{
+			WindowSeconds: 600,
+			Fields:        []string{"source", "title", "level"},
+		},
+		Flood: FloodConfig{
+			Threshold:       200,
+			WindowSeconds:   300,
+			CooldownSeconds: 900,
+		},
+		Digest: DigestConfig{
+			GracePeriodSeconds: 300,
+			MaxDelaySeconds:    3600,
+			MaxSize:            20,
+		},
+		Formatting: FormattingConfig{
+			Timezone:              "Local",
+			TimestampFormat:       notification.DefaultTimestampFormat,
+			Locale:                string(notification.LocaleEnglish),
+			ShowSource:            true,
+			DelayThresholdSeconds: 60,
+		},
 	}
 
-	if err := yaml.Unmarshal(data, cfg); err != nil {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
 		return nil, fmt.Errorf("failed to parse config file %q: %w", path, err)
 	}
+	if doc.Kind != 0 {
+		if err := resolveNode(&doc, ""); err != nil {
+			return nil, fmt.Errorf("invalid config file %q: %w", path, err)
+		}
+		if err := doc.Decode(cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %q: %w", path, err)
+		}
+	}
+
+	if err := applyEnvOverrides(reflect.ValueOf(cfg).Elem(), EnvPrefix); err != nil {
+		return nil, fmt.Errorf("invalid environment override: %w", err)
+	}
+
+	if err := new(logging.Levels).Apply(cfg.Server.LogLevel, cfg.Server.LogLevels); err != nil {
+		return nil, fmt.Errorf("server.log_level: %w", err)
+	}
+
+	if cfg.Server.LogFormat != logging.FormatJSON && cfg.Server.LogFormat != logging.FormatText {
+		return nil, fmt.Errorf("server.log_format must be json or text")
+	}
+
+	if cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1 {
+		return nil, fmt.Errorf("tracing.sample_ratio must be between 0 and 1")
+	}
+
+	if cfg.History.Enabled {
+		if cfg.History.Driver != "sqlite" {
+			return nil, fmt.Errorf("history.driver must be sqlite")
+		}
+		if cfg.History.Path == "" {
+			return nil, fmt.Errorf("history.path is required")
+		}
+	}
+	if cfg.History.RetentionDays < 0 {
+		return nil, fmt.Errorf("history.retention_days must not be negative")
+	}
+
+	if cfg.Dedup.WindowSeconds <= 0 {
+		return nil, fmt.Errorf("dedup.window_seconds must be positive")
+	}
+	if len(cfg.Dedup.Fields) == 0 {
+		return nil, fmt.Errorf("dedup.fields must not be empty")
+	}
+	for _, f := range cfg.Dedup.Fields {
+		if !notification.FingerprintFields[f] {
+			return nil, fmt.Errorf("dedup.fields: invalid field %q", f)
+		}
+	}
+
+	if cfg.Flood.Threshold <= 0 {
+		return nil, fmt.Errorf("flood.threshold must be positive")
+	}
+	if cfg.Flood.WindowSeconds <= 0 {
+		return nil, fmt.Errorf("flood.window_seconds must be positive")
+	}
+	if cfg.Flood.CooldownSeconds <= 0 {
+		return nil, fmt.Errorf("flood.cooldown_seconds must be positive")
+	}
+
+	if cfg.Digest.GracePeriodSeconds <= 0 {
+		return nil, fmt.Errorf("digest.grace_period_seconds must be positive")
+	}
+	if cfg.Digest.MaxDelaySeconds <= 0 {
+		return nil, fmt.Errorf("digest.max_delay_seconds must be positive")
+	}
+	if cfg.Digest.MaxSize <= 0 {
+		return nil, fmt.Errorf("digest.max_size must be positive")
+	}
+
+	levels, err := cfg.Levels.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	if l := notification.Level(cfg.Flood.BypassLevel); l != "" && !levels.Has(l) {
+		return nil, fmt.Errorf("flood.bypass_level: invalid level %q", cfg.Flood.BypassLevel)
+	}
+
+	if _, err := cfg.Formatting.Formatters(levels); err != nil {
+		return nil, err
+	}
+
+	if cfg.Server.MaxRequestBytes <= 0 {
+		return nil, fmt.Errorf("server.max_request_bytes must be positive")
+	}
 
 	if len(cfg.APIKeys) == 0 {
 		return nil, fmt.Errorf("at least one api_key must be configured")
@@ -95,17 +432,50 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("telegram.chat_id is required")
 	}
 
-	cfg.apiKeysMap = make(map[string]bool, len(cfg.APIKeys))
-	for _, key := range cfg.APIKeys {
-		if key != "" {
-			cfg.apiKeysMap[key] = true
+	// Keys are indexed by hash; plaintext keys are hashed and dropped
+	cfg.apiKeysMap = make(map[string]*APIKey, len(cfg.APIKeys))
+	ids := make(map[string]bool, len(cfg.APIKeys))
+	for i := range cfg.APIKeys {
+		k := &cfg.APIKeys[i]
+		switch {
+		case k.KeyHash != "" && k.Key != "":
+			return nil, fmt.Errorf("api_keys[%d]: set either key or key_hash, not both", i)
+		case k.KeyHash != "":
+			digest, err := parseKeyHash(k.KeyHash)
+			if err != nil {
+				return nil, fmt.Errorf("api_keys[%d]: %w", i, err)
+			}
+			k.KeyHash = digest
+		case k.Key != "":
+			k.KeyHash = HashAPIKey(k.Key)
+			k.Key = ""
+		default:
+			return nil, fmt.Errorf("api_keys[%d]: key_hash is required", i)
+		}
+		if k.ID == "" {
+			k.ID = DeriveAPIKeyID(k.KeyHash)
+		}
+		if k.Name == "" {
+			k.Name = k.ID
+		}
+		if ids[k.ID] {
+			return nil, fmt.Errorf("api_keys[%d]: duplicate id %q", i, k.ID)
+		}
+		if _, dup := cfg.apiKeysMap[k.KeyHash]; dup {
+			return nil, fmt.Errorf("api_keys[%d]: duplicate key for id %q", i, k.ID)
+		}
+		if err := k.Scopes.Validate(levels); err != nil {
+			return nil, fmt.Errorf("api_keys[%d]: %w", i, err)
 		}
+		ids[k.ID] = true
+		cfg.apiKeysMap[k.KeyHash] = k
 	}
 
 	return cfg, nil
 }
 
-// ValidateAPIKey checks if the provided API key is valid
-func (c *Config) ValidateAPIKey(key string) bool {
-	return c.apiKeysMap[key]
+// LookupAPIKey returns the identity for the provided raw API key
+func (c *Config) LookupAPIKey(key string) (*APIKey, bool) {
+	k, ok := c.apiKeysMap[HashAPIKey(key)]
+	return k, ok
 }
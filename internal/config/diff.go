@@ -0,0 +1,173 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Diff describes what changed between two configurations as human readable
+// lines. Secrets are never included, only the names of what changed.
+func Diff(old, new *Config) []string {
+	var changes []string
+	add := func(format string, args ...any) {
+		changes = append(changes, fmt.Sprintf(format, args...))
+	}
+
+	added, removed, changed := diffKeys(old.APIKeys, new.APIKeys)
+	if len(added) > 0 {
+		add("api_keys: added %v", added)
+	}
+	if len(removed) > 0 {
+		add("api_keys: removed %v", removed)
+	}
+	if len(changed) > 0 {
+		add("api_keys: changed %v", changed)
+	}
+
+	if old.Server.LogLevel != new.Server.LogLevel || !reflect.DeepEqual(old.Server.LogLevels, new.Server.LogLevels) {
+		add("server.log_level: %s %v -> %s %v", old.Server.LogLevel, old.Server.LogLevels, new.Server.LogLevel, new.Server.LogLevels)
+	}
+	if old.RateLimitPerMinute != new.RateLimitPerMinute {
+		add("rate_limit_per_minute: %d -> %d", old.RateLimitPerMinute, new.RateLimitPerMinute)
+	}
+	if !reflect.DeepEqual(old.Levels, new.Levels) {
+		add("levels: %v -> %v", sortedKeys(old.Levels), sortedKeys(new.Levels))
+	}
+	if !reflect.DeepEqual(old.Routing, new.Routing) {
+		add("routing: %d rules -> %d rules", len(old.Routing.Rules), len(new.Routing.Rules))
+	}
+	if !reflect.DeepEqual(old.ChannelGroups, new.ChannelGroups) {
+		add("channel_groups: %v -> %v", sortedKeys(old.ChannelGroups), sortedKeys(new.ChannelGroups))
+	}
+	if !reflect.DeepEqual(old.ChannelFilters, new.ChannelFilters) {
+		add("channel_filters: %v -> %v", sortedKeys(old.ChannelFilters), sortedKeys(new.ChannelFilters))
+	}
+	if !reflect.DeepEqual(old.Templates, new.Templates) {
+		add("templates: %v -> %v", sortedKeys(old.Templates), sortedKeys(new.Templates))
+	}
+	if !reflect.DeepEqual(old.Formatting, new.Formatting) {
+		add("formatting: changed")
+	}
+	if old.Telegram != new.Telegram {
+		add("telegram: changed")
+	}
+
+	added, removed, changed = diffWebhooks(old.Webhooks, new.Webhooks)
+	if len(added) > 0 {
+		add("webhooks: added %v", added)
+	}
+	if len(removed) > 0 {
+		add("webhooks: removed %v", removed)
+	}
+	if len(changed) > 0 {
+		add("webhooks: changed %v", changed)
+	}
+
+	for _, field := range RestartRequired(old, new) {
+		add("%s: changed, restart required to apply", field)
+	}
+
+	return changes
+}
+
+// RestartRequired lists changed settings that cannot be applied by a reload
+func RestartRequired(old, new *Config) []string {
+	var fields []string
+	if old.Server.Port != new.Server.Port {
+		fields = append(fields, "server.port")
+	}
+	if old.Server.ShutdownTimeoutSeconds != new.Server.ShutdownTimeoutSeconds {
+		fields = append(fields, "server.shutdown_timeout_seconds")
+	}
+	if old.Server.MaxRequestBytes != new.Server.MaxRequestBytes {
+		fields = append(fields, "server.max_request_bytes")
+	}
+	if old.Server.LogFormat != new.Server.LogFormat {
+		fields = append(fields, "server.log_format")
+	}
+	if old.Server.LogOutput != new.Server.LogOutput {
+		fields = append(fields, "server.log_output")
+	}
+	if old.Redis != new.Redis {
+		fields = append(fields, "redis")
+	}
+	if old.Worker != new.Worker {
+		fields = append(fields, "worker")
+	}
+	if old.Tracing != new.Tracing {
+		fields = append(fields, "tracing")
+	}
+	if old.History != new.History {
+		fields = append(fields, "history")
+	}
+	if !reflect.DeepEqual(old.Dedup, new.Dedup) {
+		fields = append(fields, "dedup")
+	}
+	if old.Flood != new.Flood {
+		fields = append(fields, "flood")
+	}
+	if old.Digest != new.Digest {
+		fields = append(fields, "digest")
+	}
+	return fields
+}
+
+func diffKeys(old, new []APIKey) (added, removed, changed []string) {
+	oldByID := make(map[string]APIKey, len(old))
+	for _, k := range old {
+		oldByID[k.ID] = k
+	}
+	newByID := make(map[string]APIKey, len(new))
+	for _, k := range new {
+		newByID[k.ID] = k
+		o, ok := oldByID[k.ID]
+		switch {
+		case !ok:
+			added = append(added, k.ID)
+		case !reflect.DeepEqual(o, k):
+			changed = append(changed, k.ID)
+		}
+	}
+	for id := range oldByID {
+		if _, ok := newByID[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+	sort.Strings(removed)
+	return added, removed, changed
+}
+
+func diffWebhooks(old, new []WebhookTarget) (added, removed, changed []string) {
+	oldByName := make(map[string]WebhookTarget, len(old))
+	for _, w := range old {
+		oldByName[w.Name] = w
+	}
+	newByName := make(map[string]WebhookTarget, len(new))
+	for _, w := range new {
+		newByName[w.Name] = w
+		o, ok := oldByName[w.Name]
+		switch {
+		case !ok:
+			added = append(added, w.Name)
+		case o != w:
+			changed = append(changed, w.Name)
+		}
+	}
+	for name := range oldByName {
+		if _, ok := newByName[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+	return added, removed, changed
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,197 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix prefixes environment variables that override config fields,
+// e.g. PNS_REDIS_ADDR overrides redis.addr
+const EnvPrefix = "PNS_"
+
+// fileSuffix marks keys and variables whose value is read from a file,
+// e.g. bot_token_file or PNS_TELEGRAM_BOT_TOKEN_FILE for Docker/systemd secrets
+const fileSuffix = "_file"
+
+// envRef matches ${VAR} and ${VAR:-default}
+var envRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// expandEnv replaces ${VAR} references in s. Unset variables expand to the
+// default if one is given and are an error otherwise.
+func expandEnv(s string) (string, error) {
+	var missing []string
+	out := envRef.ReplaceAllStringFunc(s, func(ref string) string {
+		m := envRef.FindStringSubmatch(ref)
+		if v, ok := os.LookupEnv(m[1]); ok {
+			return v
+		}
+		if strings.Contains(ref, ":-") {
+			return m[2]
+		}
+		missing = append(missing, m[1])
+		return ""
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// readSecretFile reads a secret from a file, dropping the trailing newline
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// resolveNode expands ${VAR} references in scalar values and replaces every
+// "<key>_file: <path>" mapping entry with "<key>: <file contents>"
+func resolveNode(n *yaml.Node, path string) error {
+	switch n.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for i, c := range n.Content {
+			p := path
+			if n.Kind == yaml.SequenceNode {
+				p = fmt.Sprintf("%s[%d]", path, i)
+			}
+			if err := resolveNode(c, p); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			p := joinPath(path, key.Value)
+			if err := resolveNode(value, p); err != nil {
+				return err
+			}
+
+			field, ok := strings.CutSuffix(key.Value, fileSuffix)
+			if !ok || value.Kind != yaml.ScalarNode {
+				continue
+			}
+			if hasKey(n, field) {
+				return fmt.Errorf("%s: set either %s or %s, not both", p, field, key.Value)
+			}
+			secret, err := readSecretFile(value.Value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", p, err)
+			}
+			key.Value = field
+			value.Value = secret
+			value.Tag = "!!str"
+			value.Style = 0
+		}
+	case yaml.ScalarNode:
+		v, err := expandEnv(n.Value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		n.Value = v
+	}
+	return nil
+}
+
+func hasKey(mapping *yaml.Node, key string) bool {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// applyEnvOverrides sets fields from PNS_* environment variables. The variable
+// name is the upper-cased yaml path joined with underscores. String fields also
+// accept a PNS_*_FILE variable. Lists accept comma-separated values or a YAML
+// flow sequence; other complex fields accept YAML (or JSON).
+func applyEnvOverrides(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if !field.IsExported() || tag == "" || tag == "-" {
+			continue
+		}
+
+		name := prefix + strings.ToUpper(tag)
+		fv := v.Field(i)
+
+		if fv.Kind() == reflect.Struct && fv.Type() != reflect.TypeOf(time.Time{}) {
+			if err := applyEnvOverrides(fv, name+"_"); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, ok := os.LookupEnv(name)
+		if fv.Kind() == reflect.String {
+			if path, fileOK := os.LookupEnv(name + strings.ToUpper(fileSuffix)); fileOK {
+				if ok {
+					return fmt.Errorf("set either %s or %s_FILE, not both", name, name)
+				}
+				secret, err := readSecretFile(path)
+				if err != nil {
+					return fmt.Errorf("%s_FILE: %w", name, err)
+				}
+				value, ok = secret, true
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		if err := setFromEnv(fv, value); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// setFromEnv decodes an environment variable value into a field
+func setFromEnv(fv reflect.Value, value string) error {
+	switch {
+	case fv.Kind() == reflect.String:
+		fv.SetString(value)
+		return nil
+	case fv.Kind() == reflect.Slice && !strings.HasPrefix(strings.TrimSpace(value), "["):
+		items := strings.Split(value, ",")
+		slice := reflect.MakeSlice(fv.Type(), 0, len(items))
+		for _, item := range items {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			elem := reflect.New(fv.Type().Elem())
+			node := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: item}
+			if err := node.Decode(elem.Interface()); err != nil {
+				return err
+			}
+			slice = reflect.Append(slice, elem.Elem())
+		}
+		fv.Set(slice)
+		return nil
+	default:
+		ptr := reflect.New(fv.Type())
+		if err := yaml.Unmarshal([]byte(value), ptr.Interface()); err != nil {
+			return err
+		}
+		fv.Set(ptr.Elem())
+		return nil
+	}
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/luytbq/personal-notification-service/internal/notification"
+)
+
+// FormattingConfig controls how notifications are rendered for delivery
+type FormattingConfig struct {
+	Timezone        string            `yaml:"timezone"`         // IANA name, "UTC" or "Local"
+	TimestampFormat string            `yaml:"timestamp_format"` // Go time layout
+	Locale          string            `yaml:"locale"`           // en or vi
+	ShowSource      bool              `yaml:"show_source"`
+	LevelPrefixes   map[string]string `yaml:"level_prefixes"` // e.g. {critical: "🚨"}
+	// DelayThresholdSeconds is how late a delivery must be for the timestamp
+	// to show how long ago it happened; 0 disables it
+	DelayThresholdSeconds int                                `yaml:"delay_threshold_seconds"`
+	Channels              map[string]ChannelFormattingConfig `yaml:"channels"`
+}
+
+// ChannelFormattingConfig overrides the formatting of one channel or target.
+// Empty fields inherit the global setting.
+type ChannelFormattingConfig struct {
+	Markup          string `yaml:"markup"` // text, markdown or html
+	Timezone        string `yaml:"timezone"`
+	TimestampFormat string `yaml:"timestamp_format"`
+	Locale          string `yaml:"locale"`
+}
+
+// Formatters builds the per-channel formatters for the given levels
+func (c FormattingConfig) Formatters(levels *notification.Levels) (*notification.Formatters, error) {
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("formatting.timezone: %w", err)
+	}
+
+	locale := notification.Locale(c.Locale)
+	if !locale.IsValid() {
+		return nil, fmt.Errorf("formatting.locale must be en or vi")
+	}
+
+	if c.DelayThresholdSeconds < 0 {
+		return nil, fmt.Errorf("formatting.delay_threshold_seconds must not be negative")
+	}
+
+	prefixes := make(map[notification.Level]string, len(c.LevelPrefixes))
+	for l, prefix := range c.LevelPrefixes {
+		level := notification.Level(l)
+		if !levels.Has(level) {
+			return nil, fmt.Errorf("formatting.level_prefixes: invalid level %q", l)
+		}
+		prefixes[level] = prefix
+	}
+
+	def := notification.FormatOptions{
+		Markup:          notification.MarkupText,
+		Location:        loc,
+		TimestampFormat: c.TimestampFormat,
+		Locale:          locale,
+		Levels:          levels,
+		LevelPrefixes:   prefixes,
+		ShowSource:      c.ShowSource,
+		DelayThreshold:  time.Duration(c.DelayThresholdSeconds) * time.Second,
+	}
+
+	channels := make(map[notification.Channel]notification.FormatOptions, len(c.Channels))
+	for name, cc := range c.Channels {
+		ch := notification.Channel(name)
+		if !ch.IsValid() {
+			return nil, fmt.Errorf("formatting.channels: invalid channel %q", name)
+		}
+		opts := def
+		if cc.Markup != "" {
+			opts.Markup = notification.Markup(cc.Markup)
+			if !opts.Markup.IsValid() {
+				return nil, fmt.Errorf("formatting.channels.%s.markup must be text, markdown or html", name)
+			}
+		}
+		if cc.Timezone != "" {
+			if opts.Location, err = time.LoadLocation(cc.Timezone); err != nil {
+				return nil, fmt.Errorf("formatting.channels.%s.timezone: %w", name, err)
+			}
+		}
+		if cc.TimestampFormat != "" {
+			opts.TimestampFormat = cc.TimestampFormat
+		}
+		if cc.Locale != "" {
+			opts.Locale = notification.Locale(cc.Locale)
+			if !opts.Locale.IsValid() {
+				return nil, fmt.Errorf("formatting.channels.%s.locale must be en or vi", name)
+			}
+		}
+		channels[ch] = opts
+	}
+
+	return notification.NewFormatters(def, channels), nil
+}
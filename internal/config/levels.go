@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/luytbq/personal-notification-service/internal/notification"
+)
+
+// LevelConfig defines a custom level or changes a built-in one. Empty fields
+// of a built-in level keep their defaults.
+type LevelConfig struct {
+	Severity int    `yaml:"severity"` // info is 20, warning 30, error 40, critical 50
+	Prefix   string `yaml:"prefix"`   // defaults to the upper-cased name in brackets
+	Emoji    string `yaml:"emoji"`
+	Color    string `yaml:"color"`    // "#rrggbb"
+	Priority string `yaml:"priority"` // default queue priority, normal if empty
+}
+
+// LevelsConfig maps level names to their definitions
+type LevelsConfig map[string]LevelConfig
+
+// Build merges the configured levels into the built-in ones
+func (c LevelsConfig) Build() (*notification.Levels, error) {
+	defs := make([]notification.LevelDef, 0, len(notification.BuiltinLevels)+len(c))
+	builtin := make(map[notification.Level]bool, len(notification.BuiltinLevels))
+	for _, d := range notification.BuiltinLevels {
+		if lc, ok := c[string(d.Name)]; ok {
+			d = lc.apply(d)
+		}
+		defs = append(defs, d)
+		builtin[d.Name] = true
+	}
+
+	// Sorted so that errors do not depend on map order
+	names := make([]string, 0, len(c))
+	for name := range c {
+		if !builtin[notification.Level(name)] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		lc := c[name]
+		if lc.Severity == 0 {
+			return nil, fmt.Errorf("levels.%s.severity is required", name)
+		}
+		defs = append(defs, lc.apply(notification.LevelDef{
+			Name:     notification.Level(name),
+			Prefix:   fmt.Sprintf("[%s]", strings.ToUpper(name)),
+			Priority: notification.PriorityNormal,
+		}))
+	}
+
+	levels, err := notification.NewLevels(defs)
+	if err != nil {
+		return nil, fmt.Errorf("levels: %w", err)
+	}
+	return levels, nil
+}
+
+// apply overrides the fields of d that are set in c
+func (c LevelConfig) apply(d notification.LevelDef) notification.LevelDef {
+	if c.Severity != 0 {
+		d.Severity = c.Severity
+	}
+	if c.Prefix != "" {
+		d.Prefix = c.Prefix
+	}
+	if c.Emoji != "" {
+		d.Emoji = c.Emoji
+	}
+	if c.Color != "" {
+		d.Color = c.Color
+	}
+	if c.Priority != "" {
+		d.Priority = notification.Priority(c.Priority)
+	}
+	return d
+}
@@ -0,0 +1,193 @@
+"use strict";
+
+// Refresh interval in milliseconds
+const REFRESH_MS = 10000;
+
+// Number of live events kept on screen
+const LIVE_ROWS = 50;
+
+function el(tag, text, className) {
+  const node = document.createElement(tag);
+  if (text !== undefined && text !== null) {
+    node.textContent = String(text);
+  }
+  if (className) {
+    node.className = className;
+  }
+  return node;
+}
+
+function time(value) {
+  return value ? new Date(value).toLocaleString() : "";
+}
+
+// fillTable replaces the rows of a table body; row returns a list of cells
+function fillTable(id, items, columns, row) {
+  const body = document.getElementById(id);
+  body.replaceChildren();
+  if (!items || items.length === 0) {
+    const tr = el("tr");
+    const td = el("td", "Nothing to show", "empty");
+    td.colSpan = columns;
+    tr.append(td);
+    body.append(tr);
+    return;
+  }
+  for (const item of items) {
+    const tr = el("tr");
+    tr.append(...row(item));
+    body.append(tr);
+  }
+}
+
+async function getJSON(path) {
+  const resp = await fetch(path, { credentials: "same-origin" });
+  if (!resp.ok) {
+    const body = await resp.json().catch(() => ({}));
+    const err = new Error(body.error || resp.statusText);
+    err.status = resp.status;
+    throw err;
+  }
+  return resp.json();
+}
+
+async function loadQueues() {
+  const data = await getJSON("/admin/queues");
+  fillTable("queues", data.queues, 8, (q) => [
+    el("td", q.queue), el("td", q.pending), el("td", q.active), el("td", q.scheduled),
+    el("td", q.retry), el("td", q.dead_letter), el("td", q.processed_today), el("td", q.failed_today),
+  ]);
+}
+
+async function loadChannels() {
+  const data = await getJSON("/admin/channels");
+  fillTable("channels", data.channels, 7, (c) => [
+    el("td", c.channel), el("td", c.status, "status-" + c.status), el("td", c.sent), el("td", c.failed),
+    el("td", time(c.last_success)), el("td", time(c.last_failure)), el("td", c.last_error),
+  ]);
+}
+
+async function retry(id, button) {
+  button.disabled = true;
+  const resp = await fetch("/admin/dead-letters/" + encodeURIComponent(id) + "/retry", {
+    method: "POST",
+    credentials: "same-origin",
+  });
+  if (!resp.ok) {
+    button.disabled = false;
+    showError("retry failed: " + resp.statusText);
+    return;
+  }
+  refresh();
+}
+
+async function loadDeadLetters() {
+  const data = await getJSON("/admin/dead-letters");
+  fillTable("dead-letters", data.dead_letters, 8, (d) => {
+    const button = el("button", "Retry");
+    button.addEventListener("click", () => retry(d.id, button));
+    const action = el("td");
+    action.append(button);
+    return [
+      el("td", time(d.last_failed_at)), el("td", d.channel), el("td", d.level, "level-" + d.level),
+      el("td", d.title), el("td", d.api_key_id), el("td", d.attempts), el("td", d.last_error), action,
+    ];
+  });
+}
+
+async function loadNotifications() {
+  let data;
+  try {
+    data = await getJSON("/notifications?limit=50");
+  } catch (err) {
+    if (err.status === 404 || err.status === 405) {
+      fillTable("notifications", [], 8);
+      document.querySelector("#notifications td").textContent = "History is disabled";
+      return;
+    }
+    throw err;
+  }
+  fillTable("notifications", data.notifications, 8, (n) => [
+    el("td", time(n.created_at)), el("td", n.channel), el("td", n.level, "level-" + n.level),
+    el("td", n.title), el("td", n.source), el("td", n.api_key_id),
+    el("td", n.status, "status-" + n.status), el("td", n.attempts.length),
+  ]);
+}
+
+async function loadRateLimits() {
+  const data = await getJSON("/admin/rate-limits");
+  fillTable("rate-limits", data.rate_limits, 4, (r) => [
+    el("td", r.api_key_id), el("td", r.channel),
+    el("td", Math.round(r.burst - r.available) + " / " + r.burst), el("td", Math.floor(r.available)),
+  ]);
+}
+
+function showError(message) {
+  const node = document.getElementById("error");
+  node.textContent = message;
+  node.hidden = !message;
+}
+
+async function refresh() {
+  const results = await Promise.allSettled([
+    loadQueues(), loadChannels(), loadDeadLetters(), loadNotifications(), loadRateLimits(),
+  ]);
+  const failed = results.filter((r) => r.status === "rejected").map((r) => r.reason.message);
+  showError(failed.length ? "Failed to load: " + failed.join(", ") : "");
+  document.getElementById("updated").textContent = "Updated " + new Date().toLocaleTimeString();
+}
+
+// beep plays a short tone; browsers only allow audio after the user
+// interacted with the page, which ticking the checkbox does
+let audio;
+function beep() {
+  if (!document.getElementById("beep").checked) {
+    return;
+  }
+  audio = audio || new AudioContext();
+  const osc = audio.createOscillator();
+  osc.frequency.value = 880;
+  osc.connect(audio.destination);
+  osc.start();
+  osc.stop(audio.currentTime + 0.3);
+}
+
+let lastCritical;
+function addLiveEvent(e) {
+  const body = document.getElementById("live");
+  const placeholder = body.querySelector("td.empty");
+  if (placeholder) {
+    body.replaceChildren();
+  }
+  const n = e.notification;
+  const tr = el("tr");
+  tr.append(
+    el("td", time(e.timestamp)), el("td", n.channel), el("td", n.level, "level-" + n.level),
+    el("td", n.title), el("td", n.source), el("td", e.status, "status-" + e.status), el("td", e.error),
+  );
+  body.prepend(tr);
+  while (body.rows.length > LIVE_ROWS) {
+    body.deleteRow(-1);
+  }
+  // A request sent to several channels produces one event per channel
+  if (e.type === "notification" && n.level === "critical" && n.request_id !== lastCritical) {
+    lastCritical = n.request_id;
+    beep();
+  }
+}
+
+// connectLive follows the event stream; EventSource reconnects by itself
+function connectLive() {
+  fillTable("live", [], 7);
+  const state = document.getElementById("live-state");
+  const source = new EventSource("/notifications/stream");
+  source.onopen = () => { state.textContent = "connected"; };
+  source.onerror = () => { state.textContent = "reconnecting"; };
+  for (const type of ["notification", "status"]) {
+    source.addEventListener(type, (msg) => addLiveEvent(JSON.parse(msg.data)));
+  }
+}
+
+connectLive();
+refresh();
+setInterval(refresh, REFRESH_MS);
@@ -0,0 +1,203 @@
+package dedup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/luytbq/personal-notification-service/internal/notification"
+	"github.com/luytbq/personal-notification-service/internal/queue"
+	"github.com/redis/go-redis/v9"
+)
+
+// TaskTypeSuffix is the follow-up task type, prefixed with the Redis key prefix
+const TaskTypeSuffix = "dedup:followup"
+
+// countRepeat counts a repeat if a window is open and returns the number of
+// repeats so far, or 0 if there is no open window
+var countRepeat = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+return redis.call("HINCRBY", KEYS[1], "repeats", 1)
+`)
+
+// openWindow starts a window unless one is already open. The key outlives
+// the window so that a late follow-up still finds it.
+var openWindow = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	return 0
+end
+redis.call("HSET", KEYS[1], "repeats", 0)
+redis.call("PEXPIRE", KEYS[1], ARGV[1])
+return 1
+`)
+
+// closeWindow ends a window and returns its repeats
+var closeWindow = redis.NewScript(`
+local repeats = redis.call("HGET", KEYS[1], "repeats")
+redis.call("DEL", KEYS[1])
+return tonumber(repeats) or 0
+`)
+
+// Deduper suppresses repeats of a notification within a window and sends a
+// single follow-up with the number of repeats when the window closes. Windows
+// are kept in Redis, per API key and fingerprint, so that every replica sees
+// the same counts.
+type Deduper struct {
+	rdb      *redis.Client
+	prefix   string
+	taskType string
+	window   time.Duration
+	fields   []string
+	client   *queue.Client
+	logger   *slog.Logger
+}
+
+// NewDeduper creates a Deduper using the given key prefix. Fingerprints are
+// computed from fields when the request does not carry one. Follow-ups are
+// enqueued with client.
+func NewDeduper(redisAddr, redisPassword string, redisDB int, prefix string, window time.Duration, fields []string, client *queue.Client, logger *slog.Logger) *Deduper {
+	return &Deduper{
+		rdb: redis.NewClient(&redis.Options{
+			Addr:     redisAddr,
+			Password: redisPassword,
+			DB:       redisDB,
+		}),
+		prefix:   fmt.Sprintf("%s:dedup", prefix),
+		taskType: fmt.Sprintf("%s:%s", prefix, TaskTypeSuffix),
+		window:   window,
+		fields:   fields,
+		client:   client,
+		logger:   logger,
+	}
+}
+
+// Close closes the Redis connection
+func (d *Deduper) Close() error {
+	return d.rdb.Close()
+}
+
+// TaskType returns the type of follow-up tasks, to register with the worker
+func (d *Deduper) TaskType() string {
+	return d.taskType
+}
+
+// Fingerprint returns the fingerprint of a request
+func (d *Deduper) Fingerprint(req *notification.Request) string {
+	return notification.FingerprintOf(req, d.fields)
+}
+
+// Check counts req as a repeat if a window is open for its fingerprint and
+// returns the number of repeats so far; 0 means it is not a repeat
+func (d *Deduper) Check(ctx context.Context, apiKeyID, fingerprint string) (int, error) {
+	repeats, err := countRepeat.Run(ctx, d.rdb, []string{d.key(apiKeyID, fingerprint)}).Int()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check for repeats: %w", err)
+	}
+	return repeats, nil
+}
+
+// Record opens a window for a notification that was queued on the given
+// channels and schedules its follow-up for when the window closes
+func (d *Deduper) Record(ctx context.Context, req *notification.Request, channels []notification.Channel, apiKeyID, fingerprint, requestID string) error {
+	key := d.key(apiKeyID, fingerprint)
+	opened, err := openWindow.Run(ctx, d.rdb, []string{key}, (2 * d.window).Milliseconds()).Int()
+	if err != nil {
+		return fmt.Errorf("failed to open dedup window: %w", err)
+	}
+	if opened == 0 {
+		return nil // a concurrent request opened it first
+	}
+
+	now := time.Now()
+	data, err := json.Marshal(followUpPayload{
+		Key:        key,
+		APIKeyID:   apiKeyID,
+		RequestID:  requestID,
+		Title:      req.Title,
+		Level:      req.Level,
+		Channels:   channels,
+		Source:     req.Source,
+		Tags:       req.Tags,
+		Priority:   req.Priority,
+		Recipients: req.Recipients,
+		Window:     d.window,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal follow-up: %w", err)
+	}
+
+	err = d.client.EnqueueTask(ctx, asynq.NewTask(d.taskType, data),
+		asynq.ProcessAt(now.Add(d.window)),
+		asynq.TaskID(fmt.Sprintf("%s:%d", key, now.UnixNano())),
+	)
+	if err != nil {
+		// Without a follow-up the window would only close when the key expires
+		d.rdb.Del(context.WithoutCancel(ctx), key)
+		return fmt.Errorf("failed to schedule follow-up: %w", err)
+	}
+	return nil
+}
+
+// followUpPayload is what the follow-up needs to reach the same channels
+type followUpPayload struct {
+	Key        string                          `json:"key"`
+	APIKeyID   string                          `json:"api_key_id"`
+	RequestID  string                          `json:"request_id,omitempty"`
+	Title      string                          `json:"title"`
+	Level      notification.Level              `json:"level"`
+	Channels   []notification.Channel          `json:"channels"`
+	Source     string                          `json:"source,omitempty"`
+	Tags       []string                        `json:"tags,omitempty"`
+	Priority   notification.Priority           `json:"priority,omitempty"`
+	Recipients map[notification.Channel]string `json:"recipients,omitempty"`
+	Window     time.Duration                   `json:"window"`
+}
+
+// ProcessTask closes a window and, if the notification repeated, sends
+// "repeated N more times in the last X" to the channels it was sent to
+func (d *Deduper) ProcessTask(ctx context.Context, task *asynq.Task) error {
+	var p followUpPayload
+	if err := json.Unmarshal(task.Payload(), &p); err != nil {
+		d.logger.Error("failed to parse dedup follow-up payload", slog.String("error", err.Error()))
+		return fmt.Errorf("failed to parse payload: %w: %w", err, asynq.SkipRetry)
+	}
+
+	repeats, err := closeWindow.Run(ctx, d.rdb, []string{p.Key}).Int()
+	if err != nil {
+		return fmt.Errorf("failed to close dedup window: %w", err)
+	}
+	if repeats == 0 {
+		return nil
+	}
+
+	req := &notification.Request{
+		Title:      p.Title,
+		Message:    fmt.Sprintf("Repeated %d more %s in the last %s.", repeats, notification.Plural(repeats, "time", "times"), notification.DescribeDuration(p.Window)),
+		Level:      p.Level,
+		Channels:   p.Channels,
+		Source:     p.Source,
+		Tags:       p.Tags,
+		Priority:   p.Priority,
+		Recipients: p.Recipients,
+	}
+	deliveries := d.client.Enqueue(ctx, req, p.APIKeyID, p.RequestID)
+
+	d.logger.Info("sent repeat follow-up",
+		slog.String("request_id", p.RequestID),
+		slog.String("api_key_id", p.APIKeyID),
+		slog.String("title", p.Title),
+		slog.Int("repeats", repeats),
+		slog.Int("channels", len(deliveries)),
+	)
+	return nil
+}
+
+// key returns the Redis key of a window
+func (d *Deduper) key(apiKeyID, fingerprint string) string {
+	return fmt.Sprintf("%s:%s:%s", d.prefix, apiKeyID, fingerprint)
+}
@@ -0,0 +1,184 @@
+package dedup
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/hibiken/asynq"
+	"github.com/luytbq/personal-notification-service/internal/notification"
+	"github.com/luytbq/personal-notification-service/internal/queue"
+	"github.com/luytbq/personal-notification-service/internal/stream"
+)
+
+const testWindow = 10 * time.Minute
+
+// newTestDeduper returns a Deduper with a 10 minute window on an in-memory
+// Redis, and an inspector of the queue its follow-ups go to
+func newTestDeduper(t *testing.T) (*Deduper, *miniredis.Miniredis, *asynq.Inspector) {
+	t.Helper()
+	redis := miniredis.RunT(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	hub := stream.NewHub(redis.Addr(), "", 0, "pns", logger)
+	client := queue.NewClient(redis.Addr(), "", 0, 0, logger, queue.NewQueueNames("pns"), nil, nil, hub)
+	t.Cleanup(func() { client.Close() })
+
+	d := NewDeduper(redis.Addr(), "", 0, "pns", testWindow, []string{"title"}, client, logger)
+	t.Cleanup(func() { d.Close() })
+
+	inspector := asynq.NewInspector(asynq.RedisClientOpt{Addr: redis.Addr()})
+	t.Cleanup(func() { inspector.Close() })
+	return d, redis, inspector
+}
+
+func testRequest() *notification.Request {
+	return &notification.Request{
+		Title:    "Disk full",
+		Message:  "/var",
+		Level:    notification.LevelError,
+		Channels: []notification.Channel{notification.ChannelTelegram},
+		Source:   "backup-job",
+	}
+}
+
+func TestCheckCountsRepeatsInOpenWindow(t *testing.T) {
+	d, _, _ := newTestDeduper(t)
+	ctx := context.Background()
+	req := testRequest()
+	fp := d.Fingerprint(req)
+
+	check := func(apiKeyID string, want int) {
+		t.Helper()
+		got, err := d.Check(ctx, apiKeyID, fp)
+		if err != nil {
+			t.Fatalf("Check: %v", err)
+		}
+		if got != want {
+			t.Errorf("Check(%s) = %d, want %d", apiKeyID, got, want)
+		}
+	}
+
+	check("ops", 0) // no window yet
+	if err := d.Record(ctx, req, req.Channels, "ops", fp, "req-1"); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	check("ops", 1)
+	check("ops", 2)
+	check("ci", 0) // windows are per API key
+}
+
+func TestRecordSchedulesFollowUpAtWindowEnd(t *testing.T) {
+	d, redis, inspector := newTestDeduper(t)
+	ctx := context.Background()
+	req := testRequest()
+	fp := d.Fingerprint(req)
+
+	start := time.Now()
+	if err := d.Record(ctx, req, req.Channels, "ops", fp, "req-1"); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	// A window that is already open is left alone
+	if err := d.Record(ctx, req, req.Channels, "ops", fp, "req-2"); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	tasks, err := inspector.ListScheduledTasks("pns:notifications")
+	if err != nil {
+		t.Fatalf("ListScheduledTasks: %v", err)
+	}
+	if len(tasks) != 1 {
+		t.Fatalf("got %d scheduled follow-ups, want 1", len(tasks))
+	}
+	task := tasks[0]
+	if task.Type != d.TaskType() {
+		t.Errorf("task type = %s, want %s", task.Type, d.TaskType())
+	}
+	// asynq keeps the time in whole seconds
+	if at := task.NextProcessAt; at.Before(start.Add(testWindow).Truncate(time.Second)) || at.After(time.Now().Add(testWindow)) {
+		t.Errorf("follow-up at %s, want %s after %s", at, testWindow, start)
+	}
+
+	var p followUpPayload
+	if err := json.Unmarshal(task.Payload, &p); err != nil {
+		t.Fatalf("failed to decode payload: %v", err)
+	}
+	if p.RequestID != "req-1" || p.Title != req.Title || p.Window != testWindow {
+		t.Errorf("payload = %+v, want the first request's", p)
+	}
+
+	// The key outlives the window for a late follow-up
+	if ttl := redis.TTL(p.Key); ttl != 2*testWindow {
+		t.Errorf("window TTL = %s, want %s", ttl, 2*testWindow)
+	}
+}
+
+func TestProcessTask(t *testing.T) {
+	tests := []struct {
+		name    string
+		repeats int
+		want    string // message of the follow-up; empty for none
+	}{
+		{name: "no repeats", repeats: 0},
+		{name: "one repeat", repeats: 1, want: "Repeated 1 more time in the last 10 minutes."},
+		{name: "repeats", repeats: 3, want: "Repeated 3 more times in the last 10 minutes."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, redis, inspector := newTestDeduper(t)
+			ctx := context.Background()
+			req := testRequest()
+			fp := d.Fingerprint(req)
+
+			if err := d.Record(ctx, req, req.Channels, "ops", fp, "req-1"); err != nil {
+				t.Fatalf("Record: %v", err)
+			}
+			for i := 0; i < tt.repeats; i++ {
+				if _, err := d.Check(ctx, "ops", fp); err != nil {
+					t.Fatalf("Check: %v", err)
+				}
+			}
+			scheduled, err := inspector.ListScheduledTasks("pns:notifications")
+			if err != nil || len(scheduled) != 1 {
+				t.Fatalf("ListScheduledTasks = %d tasks, %v; want 1", len(scheduled), err)
+			}
+
+			if err := d.ProcessTask(ctx, asynq.NewTask(scheduled[0].Type, scheduled[0].Payload)); err != nil {
+				t.Fatalf("ProcessTask: %v", err)
+			}
+
+			// The window is closed, so the next one is not a repeat
+			if redis.Exists(d.key("ops", fp)) {
+				t.Error("window is still open")
+			}
+			if repeats, err := d.Check(ctx, "ops", fp); err != nil || repeats != 0 {
+				t.Errorf("Check after the window = %d, %v; want 0", repeats, err)
+			}
+
+			pending, err := inspector.ListPendingTasks("pns:notifications")
+			if err != nil {
+				t.Fatalf("ListPendingTasks: %v", err)
+			}
+			if tt.want == "" {
+				if len(pending) != 0 {
+					t.Errorf("got %d follow-ups, want none", len(pending))
+				}
+				return
+			}
+			if len(pending) != 1 {
+				t.Fatalf("got %d follow-ups, want 1", len(pending))
+			}
+			var p queue.NotificationPayload
+			if err := json.Unmarshal(pending[0].Payload, &p); err != nil {
+				t.Fatalf("failed to decode follow-up: %v", err)
+			}
+			if p.Message != tt.want || p.Title != req.Title || p.Channel != notification.ChannelTelegram {
+				t.Errorf("follow-up = %q %q on %s, want %q %q on %s",
+					p.Title, p.Message, p.Channel, req.Title, tt.want, notification.ChannelTelegram)
+			}
+		})
+	}
+}
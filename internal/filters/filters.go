@@ -0,0 +1,227 @@
+package filters
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/luytbq/personal-notification-service/internal/config"
+	"github.com/luytbq/personal-notification-service/internal/notification"
+)
+
+// Quiet hours modes
+const (
+	ModeHold   = "hold"   // deliver at the end of the window
+	ModeSilent = "silent" // deliver now without sound
+)
+
+// window is a daily time range in minutes since midnight. A window whose end
+// is before its start spans midnight.
+type window struct {
+	start, end int
+}
+
+// filter is a compiled channel filter
+type filter struct {
+	minLevel notification.Level
+	windows  []window
+	location *time.Location
+	mode     string
+	bypass   notification.Level
+	digest   bool
+}
+
+// Set holds the filters of each configured channel or target
+type Set struct {
+	filters map[notification.Channel]*filter
+	levels  *notification.Levels
+}
+
+// NewSet compiles the configured channel filters. Keys name concrete
+// channels or targets, e.g. email or telegram:ops.
+func NewSet(defs map[string]config.ChannelFilterConfig, levels *notification.Levels) (*Set, error) {
+	s := &Set{
+		filters: make(map[notification.Channel]*filter, len(defs)),
+		levels:  levels,
+	}
+
+	for name, def := range defs {
+		ch := notification.Channel(name)
+		if !ch.IsValid() {
+			return nil, fmt.Errorf("channel_filters: invalid channel %q", name)
+		}
+
+		f := &filter{
+			minLevel: notification.Level(def.MinLevel),
+			mode:     def.QuietHours.Mode,
+			bypass:   notification.Level(def.QuietHours.BypassLevel),
+			digest:   def.Digest,
+		}
+		if f.minLevel != "" && !levels.Has(f.minLevel) {
+			return nil, fmt.Errorf("channel_filters.%s: invalid min_level %q", name, def.MinLevel)
+		}
+
+		if f.mode == "" {
+			f.mode = ModeHold
+		}
+		if f.mode != ModeHold && f.mode != ModeSilent {
+			return nil, fmt.Errorf("channel_filters.%s.quiet_hours.mode must be hold or silent", name)
+		}
+		if f.bypass == "" {
+			f.bypass = notification.LevelCritical
+		}
+		if !levels.Has(f.bypass) {
+			return nil, fmt.Errorf("channel_filters.%s.quiet_hours: invalid bypass_level %q", name, def.QuietHours.BypassLevel)
+		}
+
+		tz := def.QuietHours.Timezone
+		if tz == "" {
+			tz = "Local"
+		}
+		var err error
+		if f.location, err = time.LoadLocation(tz); err != nil {
+			return nil, fmt.Errorf("channel_filters.%s.quiet_hours.timezone: %w", name, err)
+		}
+
+		for _, w := range def.QuietHours.Windows {
+			parsed, err := parseWindow(w)
+			if err != nil {
+				return nil, fmt.Errorf("channel_filters.%s.quiet_hours: %w", name, err)
+			}
+			f.windows = append(f.windows, parsed)
+		}
+
+		s.filters[ch] = f
+	}
+
+	return s, nil
+}
+
+// parseWindow parses "HH:MM-HH:MM"
+func parseWindow(s string) (window, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return window{}, fmt.Errorf("invalid window %q: must have the form HH:MM-HH:MM", s)
+	}
+	start, err := parseClock(from)
+	if err != nil {
+		return window{}, fmt.Errorf("invalid window %q: %w", s, err)
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return window{}, fmt.Errorf("invalid window %q: %w", s, err)
+	}
+	if start == end {
+		return window{}, fmt.Errorf("invalid window %q: start and end must differ", s)
+	}
+	return window{start: start, end: end}, nil
+}
+
+// parseClock parses "HH:MM" into minutes since midnight
+func parseClock(s string) (int, error) {
+	hh, mm, ok := strings.Cut(strings.TrimSpace(s), ":")
+	h, errH := strconv.Atoi(hh)
+	m, errM := strconv.Atoi(mm)
+	if !ok || errH != nil || errM != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return h*60 + m, nil
+}
+
+// Decide reports whether a notification of the given level goes to the
+// channel at all and, if so, how it is delivered at time now. Levels at or
+// above the bypass level are neither held nor batched into digests.
+func (s *Set) Decide(ch notification.Channel, level notification.Level, now time.Time) (notification.Schedule, bool) {
+	f, ok := s.filters[ch]
+	if !ok {
+		return notification.Schedule{}, true
+	}
+	if f.minLevel != "" && !s.levels.AtLeast(level, f.minLevel) {
+		return notification.Schedule{}, false
+	}
+	if s.Bypasses(ch, level) {
+		return notification.Schedule{}, true
+	}
+
+	schedule := notification.Schedule{Digest: f.digest}
+	if end, quiet := f.quietUntil(now); quiet {
+		if f.mode == ModeSilent {
+			schedule.Silent = true
+		} else {
+			schedule.DeliverAt = end
+		}
+	}
+	return schedule, true
+}
+
+// Bypasses reports whether level is at or above the channel's bypass level,
+// so that it is neither held nor batched. Channels without a filter bypass
+// at critical.
+func (s *Set) Bypasses(ch notification.Channel, level notification.Level) bool {
+	bypass := notification.LevelCritical
+	if f, ok := s.filters[ch]; ok {
+		bypass = f.bypass
+	}
+	return s.levels.AtLeast(level, bypass)
+}
+
+// quietUntil returns the end of the quiet period now falls in. Adjacent or
+// overlapping windows are merged.
+func (f *filter) quietUntil(now time.Time) (time.Time, bool) {
+	t := now.In(f.location)
+	quiet := false
+	// Each pass moves past one window; more passes than windows means they
+	// cover the whole day, so stop there
+	for range len(f.windows) + 1 {
+		moved := false
+		for _, w := range f.windows {
+			if end, ok := w.endAfter(t); ok {
+				t, moved, quiet = end, true, true
+			}
+		}
+		if !moved {
+			break
+		}
+	}
+	return t, quiet
+}
+
+// endAfter returns the end of the window if t is inside it
+func (w window) endAfter(t time.Time) (time.Time, bool) {
+	minute := t.Hour()*60 + t.Minute()
+	// Wall clock time, so that windows follow daylight saving changes
+	endOn := func(day int) time.Time {
+		return time.Date(t.Year(), t.Month(), day, w.end/60, w.end%60, 0, 0, t.Location())
+	}
+
+	if w.start < w.end {
+		return endOn(t.Day()), minute >= w.start && minute < w.end
+	}
+	// Spans midnight: either late evening, ending tomorrow, or early morning
+	if minute >= w.start {
+		return endOn(t.Day() + 1), true
+	}
+	return endOn(t.Day()), minute < w.end
+}
+
+// Apply decides every target channel of a request at time now. It returns the
+// channels that still receive it, and records in req.Schedules those that
+// hold it or send it silently.
+func (s *Set) Apply(req *notification.Request, targets []notification.Channel, now time.Time) (kept, skipped []notification.Channel) {
+	for _, ch := range targets {
+		schedule, ok := s.Decide(ch, req.Level, now)
+		if !ok {
+			skipped = append(skipped, ch)
+			continue
+		}
+		if schedule != (notification.Schedule{}) {
+			if req.Schedules == nil {
+				req.Schedules = make(map[notification.Channel]notification.Schedule)
+			}
+			req.Schedules[ch] = schedule
+		}
+		kept = append(kept, ch)
+	}
+	return kept, skipped
+}
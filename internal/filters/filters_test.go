@@ -0,0 +1,145 @@
+package filters
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/luytbq/personal-notification-service/internal/config"
+	"github.com/luytbq/personal-notification-service/internal/notification"
+)
+
+func newTestSet(t *testing.T) *Set {
+	t.Helper()
+	s, err := NewSet(map[string]config.ChannelFilterConfig{
+		"telegram": {
+			MinLevel: "warning",
+			QuietHours: config.QuietHoursConfig{
+				Windows:     []string{"23:00-07:00"},
+				Timezone:    "UTC",
+				BypassLevel: "error",
+			},
+		},
+		"webhook:ops": {
+			QuietHours: config.QuietHoursConfig{Windows: []string{"22:00-06:00"}, Timezone: "UTC", Mode: ModeSilent},
+		},
+		"telegram:ops": {
+			QuietHours: config.QuietHoursConfig{Windows: []string{"12:00-13:00", "22:00-23:00", "23:00-01:00"}, Timezone: "UTC"},
+		},
+		"webhook:digest": {Digest: true},
+	}, notification.DefaultLevels)
+	if err != nil {
+		t.Fatalf("NewSet: %v", err)
+	}
+	return s
+}
+
+// at returns the given time on 14 March 2026, UTC
+func at(hour, minute int) time.Time {
+	return time.Date(2026, 3, 14, hour, minute, 0, 0, time.UTC)
+}
+
+func TestDecide(t *testing.T) {
+	s := newTestSet(t)
+
+	tests := []struct {
+		name    string
+		channel notification.Channel
+		level   notification.Level
+		now     time.Time
+		want    notification.Schedule
+		ok      bool
+	}{
+		{"below min level", "telegram", notification.LevelInfo, at(12, 0), notification.Schedule{}, false},
+		{"outside quiet hours", "telegram", notification.LevelWarning, at(12, 0), notification.Schedule{}, true},
+		{"held before midnight", "telegram", notification.LevelWarning, at(23, 30), notification.Schedule{DeliverAt: at(31, 0)}, true},
+		{"held after midnight", "telegram", notification.LevelWarning, at(3, 0), notification.Schedule{DeliverAt: at(7, 0)}, true},
+		{"start is inside", "telegram", notification.LevelWarning, at(23, 0), notification.Schedule{DeliverAt: at(31, 0)}, true},
+		{"end is outside", "telegram", notification.LevelWarning, at(7, 0), notification.Schedule{}, true},
+		{"bypass level", "telegram", notification.LevelError, at(23, 30), notification.Schedule{}, true},
+		{"silent mode", "webhook:ops", notification.LevelInfo, at(22, 30), notification.Schedule{Silent: true}, true},
+		{"silent mode after midnight", "webhook:ops", notification.LevelInfo, at(5, 59), notification.Schedule{Silent: true}, true},
+		{"default bypass level", "webhook:ops", notification.LevelCritical, at(22, 30), notification.Schedule{}, true},
+		{"adjacent windows merge", "telegram:ops", notification.LevelInfo, at(22, 30), notification.Schedule{DeliverAt: at(25, 0)}, true},
+		{"separate windows", "telegram:ops", notification.LevelInfo, at(12, 30), notification.Schedule{DeliverAt: at(13, 0)}, true},
+		{"digest", "webhook:digest", notification.LevelError, at(12, 0), notification.Schedule{Digest: true}, true},
+		{"digest bypass level", "webhook:digest", notification.LevelCritical, at(12, 0), notification.Schedule{}, true},
+		{"no filter", "webhook:other", notification.LevelInfo, at(23, 30), notification.Schedule{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := s.Decide(tt.channel, tt.level, tt.now)
+			if ok != tt.ok {
+				t.Fatalf("ok = %t, want %t", ok, tt.ok)
+			}
+			if !got.DeliverAt.Equal(tt.want.DeliverAt) || got.Silent != tt.want.Silent || got.Digest != tt.want.Digest {
+				t.Errorf("schedule = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecideTimezone(t *testing.T) {
+	s, err := NewSet(map[string]config.ChannelFilterConfig{
+		"telegram": {QuietHours: config.QuietHoursConfig{Windows: []string{"23:00-07:00"}, Timezone: "Asia/Ho_Chi_Minh"}},
+	}, notification.DefaultLevels)
+	if err != nil {
+		t.Skipf("time zone is not available: %v", err)
+	}
+
+	// 17:30 UTC is 00:30 in Saigon, UTC+7, so quiet until 00:00 UTC
+	got, _ := s.Decide("telegram", notification.LevelInfo, at(17, 30))
+	if want := at(24, 0); !got.DeliverAt.Equal(want) {
+		t.Errorf("DeliverAt = %s, want %s", got.DeliverAt, want)
+	}
+}
+
+func TestApply(t *testing.T) {
+	s := newTestSet(t)
+	req := &notification.Request{Level: notification.LevelInfo}
+	targets := []notification.Channel{"telegram", "webhook:ops", "webhook:digest", "webhook:other"}
+
+	kept, skipped := s.Apply(req, targets, at(23, 30))
+	if want := []notification.Channel{"webhook:ops", "webhook:digest", "webhook:other"}; !slices.Equal(kept, want) {
+		t.Errorf("kept = %v, want %v", kept, want)
+	}
+	if want := []notification.Channel{"telegram"}; !slices.Equal(skipped, want) {
+		t.Errorf("skipped = %v, want %v", skipped, want)
+	}
+
+	want := map[notification.Channel]notification.Schedule{
+		"webhook:ops":    {Silent: true},
+		"webhook:digest": {Digest: true},
+	}
+	if len(req.Schedules) != len(want) {
+		t.Fatalf("schedules = %+v, want %+v", req.Schedules, want)
+	}
+	for ch, schedule := range want {
+		if got := req.Schedules[ch]; got != schedule {
+			t.Errorf("schedule of %s = %+v, want %+v", ch, got, schedule)
+		}
+	}
+}
+
+func TestNewSetErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		def  config.ChannelFilterConfig
+	}{
+		{"invalid min level", config.ChannelFilterConfig{MinLevel: "loud"}},
+		{"invalid mode", config.ChannelFilterConfig{QuietHours: config.QuietHoursConfig{Mode: "mute"}}},
+		{"invalid bypass level", config.ChannelFilterConfig{QuietHours: config.QuietHoursConfig{BypassLevel: "loud"}}},
+		{"invalid timezone", config.ChannelFilterConfig{QuietHours: config.QuietHoursConfig{Timezone: "Mars/Olympus"}}},
+		{"window without end", config.ChannelFilterConfig{QuietHours: config.QuietHoursConfig{Windows: []string{"23:00"}}}},
+		{"invalid time", config.ChannelFilterConfig{QuietHours: config.QuietHoursConfig{Windows: []string{"23:00-24:00"}}}},
+		{"empty window", config.ChannelFilterConfig{QuietHours: config.QuietHoursConfig{Windows: []string{"07:00-07:00"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defs := map[string]config.ChannelFilterConfig{"telegram": tt.def}
+			if _, err := NewSet(defs, notification.DefaultLevels); err == nil {
+				t.Error("NewSet succeeded, want an error")
+			}
+		})
+	}
+}
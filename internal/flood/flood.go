@@ -0,0 +1,339 @@
+package flood
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/luytbq/personal-notification-service/internal/notification"
+	"github.com/luytbq/personal-notification-service/internal/queue"
+	"github.com/redis/go-redis/v9"
+)
+
+// TaskTypeSuffix is the unmute task type, prefixed with the Redis key prefix
+const TaskTypeSuffix = "flood:unmute"
+
+// Summary limits: titles counted while muted, and shown when the mute lifts
+const (
+	trackedTitles = 20
+	summaryTitles = 5
+)
+
+// State is the outcome of a flood check
+type State int
+
+const (
+	Allowed State = iota // deliver as usual
+	Tripped              // deliver, but this notification muted the source
+	Muted                // the source is muted; suppress the notification
+)
+
+// check adds a notification's fingerprint to the source's set for the window;
+// repeats of a fingerprint do not count towards the threshold. While the
+// source is muted, the notification is added to the summary instead. Fields
+// of the mute hash: suppressed, level:<level>, title:<title>, titles (number
+// of title fields) and the most severe level.
+var check = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	redis.call("HINCRBY", KEYS[1], "suppressed", 1)
+	redis.call("HINCRBY", KEYS[1], "level:" .. ARGV[1], 1)
+	if tonumber(ARGV[2]) > tonumber(redis.call("HGET", KEYS[1], "max_severity") or "0") then
+		redis.call("HSET", KEYS[1], "max_severity", ARGV[2], "max_level", ARGV[1])
+	end
+	local title = "title:" .. ARGV[3]
+	if redis.call("HEXISTS", KEYS[1], title) == 1 then
+		redis.call("HINCRBY", KEYS[1], title, 1)
+	elseif tonumber(redis.call("HGET", KEYS[1], "titles") or "0") < tonumber(ARGV[4]) then
+		redis.call("HINCRBY", KEYS[1], "titles", 1)
+		redis.call("HSET", KEYS[1], title, 1)
+	end
+	return 2
+end
+local fresh = redis.call("EXISTS", KEYS[2]) == 0
+if redis.call("SADD", KEYS[2], ARGV[8]) == 0 then
+	return 0
+end
+if fresh then
+	redis.call("PEXPIRE", KEYS[2], ARGV[6])
+end
+if redis.call("SCARD", KEYS[2]) < tonumber(ARGV[5]) then
+	return 0
+end
+redis.call("DEL", KEYS[2])
+redis.call("HSET", KEYS[1], "suppressed", 0)
+redis.call("PEXPIRE", KEYS[1], ARGV[7])
+return 1
+`)
+
+// unmute lifts a mute and returns its hash
+var unmute = redis.NewScript(`
+local fields = redis.call("HGETALL", KEYS[1])
+redis.call("DEL", KEYS[1])
+return fields
+`)
+
+// Muter mutes a source that sends too many distinct notifications within a
+// window, announces it once, and sends a summary of what was suppressed when
+// the mute lifts. Counts and mutes are kept in Redis, per API key and
+// source, so that every replica sees them.
+type Muter struct {
+	rdb       *redis.Client
+	prefix    string
+	taskType  string
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+	bypass    notification.Level
+	fields    []string
+	client    *queue.Client
+	logger    *slog.Logger
+}
+
+// NewMuter creates a Muter using the given key prefix. A source that sends
+// threshold distinct notifications within window is muted for cooldown.
+// Notifications are told apart by their fingerprint, computed from fields
+// when the request does not carry one. Levels at or above bypass are never
+// muted; an empty bypass mutes every level. Announcements and summaries are
+// enqueued with client.
+func NewMuter(redisAddr, redisPassword string, redisDB int, prefix string, threshold int, window, cooldown time.Duration, bypass notification.Level, fields []string, client *queue.Client, logger *slog.Logger) *Muter {
+	return &Muter{
+		rdb: redis.NewClient(&redis.Options{
+			Addr:     redisAddr,
+			Password: redisPassword,
+			DB:       redisDB,
+		}),
+		prefix:    fmt.Sprintf("%s:flood", prefix),
+		taskType:  fmt.Sprintf("%s:%s", prefix, TaskTypeSuffix),
+		threshold: threshold,
+		window:    window,
+		cooldown:  cooldown,
+		bypass:    bypass,
+		fields:    fields,
+		client:    client,
+		logger:    logger,
+	}
+}
+
+// Close closes the Redis connection
+func (m *Muter) Close() error {
+	return m.rdb.Close()
+}
+
+// TaskType returns the type of unmute tasks, to register with the worker
+func (m *Muter) TaskType() string {
+	return m.taskType
+}
+
+// Check counts a request against its source's threshold. Requests without a
+// source and levels at or above the bypass level are always allowed.
+func (m *Muter) Check(ctx context.Context, req *notification.Request, apiKeyID string, levels *notification.Levels) (State, error) {
+	if req.Source == "" || (m.bypass != "" && levels.AtLeast(req.Level, m.bypass)) {
+		return Allowed, nil
+	}
+
+	keys := []string{m.muteKey(apiKeyID, req.Source), m.rateKey(apiKeyID, req.Source)}
+	state, err := check.Run(ctx, m.rdb, keys,
+		string(req.Level),
+		levels.Severity(req.Level),
+		req.Title,
+		trackedTitles,
+		m.threshold,
+		m.window.Milliseconds(),
+		// The key outlives the mute so that a late unmute still finds it
+		(2 * m.cooldown).Milliseconds(),
+		notification.FingerprintOf(req, m.fields),
+	).Int()
+	if err != nil {
+		return Allowed, fmt.Errorf("failed to check flood rate: %w", err)
+	}
+	return State(state), nil
+}
+
+// Announce sends the mute alert for a request that tripped its source's
+// threshold to the given channels, and schedules the unmute
+func (m *Muter) Announce(ctx context.Context, req *notification.Request, channels []notification.Channel, apiKeyID, requestID string) error {
+	key := m.muteKey(apiKeyID, req.Source)
+	now := time.Now()
+	data, err := json.Marshal(unmutePayload{
+		Key:        key,
+		APIKeyID:   apiKeyID,
+		RequestID:  requestID,
+		Source:     req.Source,
+		Channels:   channels,
+		Recipients: req.Recipients,
+		Cooldown:   m.cooldown,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal unmute: %w", err)
+	}
+
+	err = m.client.EnqueueTask(ctx, asynq.NewTask(m.taskType, data),
+		asynq.ProcessAt(now.Add(m.cooldown)),
+		asynq.TaskID(fmt.Sprintf("%s:%d", key, now.UnixNano())),
+	)
+	if err != nil {
+		// Without an unmute there would be no summary, so do not mute at all
+		m.rdb.Del(context.WithoutCancel(ctx), key)
+		return fmt.Errorf("failed to schedule unmute: %w", err)
+	}
+
+	alert := &notification.Request{
+		Title: fmt.Sprintf("Source %s muted", req.Source),
+		Message: fmt.Sprintf("Source %s muted for %s after %d distinct notifications in %s.",
+			req.Source, notification.DescribeDuration(m.cooldown), m.threshold, notification.DescribeDuration(m.window)),
+		Level:      notification.LevelWarning,
+		Channels:   channels,
+		Source:     req.Source,
+		Recipients: req.Recipients,
+	}
+	m.client.Enqueue(ctx, alert, apiKeyID, requestID)
+
+	m.logger.Info("source muted",
+		slog.String("request_id", requestID),
+		slog.String("api_key_id", apiKeyID),
+		slog.String("source", req.Source),
+		slog.Duration("cooldown", m.cooldown),
+	)
+	return nil
+}
+
+// unmutePayload is what the unmute needs to reach the channels of the alert
+type unmutePayload struct {
+	Key        string                          `json:"key"`
+	APIKeyID   string                          `json:"api_key_id"`
+	RequestID  string                          `json:"request_id,omitempty"`
+	Source     string                          `json:"source"`
+	Channels   []notification.Channel          `json:"channels"`
+	Recipients map[notification.Channel]string `json:"recipients,omitempty"`
+	Cooldown   time.Duration                   `json:"cooldown"`
+}
+
+// ProcessTask lifts a mute and sends a summary of what was suppressed to the
+// channels the alert was sent to
+func (m *Muter) ProcessTask(ctx context.Context, task *asynq.Task) error {
+	var p unmutePayload
+	if err := json.Unmarshal(task.Payload(), &p); err != nil {
+		m.logger.Error("failed to parse unmute payload", slog.String("error", err.Error()))
+		return fmt.Errorf("failed to parse payload: %w: %w", err, asynq.SkipRetry)
+	}
+
+	fields, err := unmute.Run(ctx, m.rdb, []string{p.Key}).StringSlice()
+	if err != nil {
+		return fmt.Errorf("failed to lift mute: %w", err)
+	}
+	s := parseSummary(fields)
+
+	req := &notification.Request{
+		Title:      fmt.Sprintf("Source %s unmuted", p.Source),
+		Message:    s.message(p.Cooldown),
+		Level:      notification.LevelInfo,
+		Channels:   p.Channels,
+		Source:     p.Source,
+		Recipients: p.Recipients,
+	}
+	if s.maxLevel != "" {
+		req.Level = s.maxLevel
+	}
+	deliveries := m.client.Enqueue(ctx, req, p.APIKeyID, p.RequestID)
+
+	m.logger.Info("source unmuted",
+		slog.String("request_id", p.RequestID),
+		slog.String("api_key_id", p.APIKeyID),
+		slog.String("source", p.Source),
+		slog.Int("suppressed", s.suppressed),
+		slog.Int("channels", len(deliveries)),
+	)
+	return nil
+}
+
+// count is a level or title and how often it was suppressed
+type count struct {
+	name string
+	n    int
+}
+
+// summary is what was suppressed during a mute
+type summary struct {
+	suppressed int
+	maxLevel   notification.Level
+	levels     []count
+	titles     []count
+}
+
+// parseSummary reads a mute hash, returned by HGETALL as field, value pairs
+func parseSummary(fields []string) summary {
+	var s summary
+	for i := 0; i+1 < len(fields); i += 2 {
+		field, value := fields[i], fields[i+1]
+		n, _ := strconv.Atoi(value) // 0 for max_level
+		switch {
+		case field == "suppressed":
+			s.suppressed = n
+		case field == "max_level":
+			s.maxLevel = notification.Level(value)
+		case strings.HasPrefix(field, "level:"):
+			s.levels = append(s.levels, count{strings.TrimPrefix(field, "level:"), n})
+		case strings.HasPrefix(field, "title:"):
+			s.titles = append(s.titles, count{strings.TrimPrefix(field, "title:"), n})
+		}
+	}
+	byCount := func(c []count) {
+		sort.Slice(c, func(i, j int) bool {
+			if c[i].n != c[j].n {
+				return c[i].n > c[j].n
+			}
+			return c[i].name < c[j].name
+		})
+	}
+	byCount(s.levels)
+	byCount(s.titles)
+	return s
+}
+
+// message renders the summary, e.g.
+//
+//	Suppressed 812 notifications while muted for 15 minutes: 790 info, 22 error.
+//
+//	Most frequent:
+//	400× Disk full
+func (s summary) message(cooldown time.Duration) string {
+	if s.suppressed == 0 {
+		return fmt.Sprintf("No notifications were suppressed while muted for %s.", notification.DescribeDuration(cooldown))
+	}
+
+	levels := make([]string, len(s.levels))
+	for i, l := range s.levels {
+		levels[i] = fmt.Sprintf("%d %s", l.n, l.name)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Suppressed %d %s while muted for %s: %s.",
+		s.suppressed, notification.Plural(s.suppressed, "notification", "notifications"), notification.DescribeDuration(cooldown), strings.Join(levels, ", "))
+	if len(s.titles) > 0 {
+		b.WriteString("\n\nMost frequent:")
+		for i, t := range s.titles {
+			if i == summaryTitles {
+				break
+			}
+			fmt.Fprintf(&b, "\n%d× %s", t.n, t.name)
+		}
+	}
+	return b.String()
+}
+
+// muteKey returns the Redis key of a source's mute
+func (m *Muter) muteKey(apiKeyID, source string) string {
+	return fmt.Sprintf("%s:mute:%s:%s", m.prefix, apiKeyID, source)
+}
+
+// rateKey returns the Redis key of the fingerprints a source sent in the
+// current window
+func (m *Muter) rateKey(apiKeyID, source string) string {
+	return fmt.Sprintf("%s:rate:%s:%s", m.prefix, apiKeyID, source)
+}
@@ -0,0 +1,195 @@
+package flood
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/luytbq/personal-notification-service/internal/notification"
+)
+
+// newTestMuter returns a Muter on an in-memory Redis that mutes a source for
+// 15 minutes after 3 distinct titles within a minute, except critical ones
+func newTestMuter(t *testing.T) (*Muter, *miniredis.Miniredis) {
+	t.Helper()
+	redis := miniredis.RunT(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	m := NewMuter(redis.Addr(), "", 0, "pns", 3, time.Minute, 15*time.Minute, notification.LevelCritical, []string{"title"}, nil, logger)
+	t.Cleanup(func() { m.Close() })
+	return m, redis
+}
+
+func TestCheck(t *testing.T) {
+	type step struct {
+		source string
+		title  string
+		level  notification.Level
+		want   State
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "repeats do not count",
+			steps: []step{
+				{"backup-job", "Disk full", notification.LevelError, Allowed},
+				{"backup-job", "Disk full", notification.LevelError, Allowed},
+				{"backup-job", "Disk full", notification.LevelError, Allowed},
+				{"backup-job", "Disk full", notification.LevelError, Allowed},
+				{"backup-job", "Disk slow", notification.LevelError, Allowed},
+			},
+		},
+		{
+			name: "distinct notifications trip and mute",
+			steps: []step{
+				{"backup-job", "Disk 1", notification.LevelError, Allowed},
+				{"backup-job", "Disk 2", notification.LevelError, Allowed},
+				{"backup-job", "Disk 3", notification.LevelError, Tripped},
+				{"backup-job", "Disk 1", notification.LevelError, Muted},
+				{"backup-job", "Disk 4", notification.LevelInfo, Muted},
+			},
+		},
+		{
+			name: "sources count separately",
+			steps: []step{
+				{"backup-job", "Disk 1", notification.LevelError, Allowed},
+				{"backup-job", "Disk 2", notification.LevelError, Allowed},
+				{"deploy", "Disk 3", notification.LevelError, Allowed},
+				{"", "Disk 4", notification.LevelError, Allowed},
+				{"", "Disk 5", notification.LevelError, Allowed},
+			},
+		},
+		{
+			name: "bypass level",
+			steps: []step{
+				{"backup-job", "Disk 1", notification.LevelCritical, Allowed},
+				{"backup-job", "Disk 2", notification.LevelCritical, Allowed},
+				{"backup-job", "Disk 3", notification.LevelCritical, Allowed},
+				{"backup-job", "Disk 4", notification.LevelError, Allowed},
+				{"backup-job", "Disk 5", notification.LevelError, Allowed},
+				{"backup-job", "Disk 6", notification.LevelError, Tripped},
+				{"backup-job", "Disk 7", notification.LevelCritical, Allowed},
+				{"backup-job", "Disk 8", notification.LevelWarning, Muted},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := newTestMuter(t)
+			for i, s := range tt.steps {
+				req := &notification.Request{Title: s.title, Level: s.level, Source: s.source}
+				got, err := m.Check(context.Background(), req, "test", notification.DefaultLevels)
+				if err != nil {
+					t.Fatalf("step %d: Check: %v", i, err)
+				}
+				if got != s.want {
+					t.Errorf("step %d (%s %q): got state %d, want %d", i, s.source, s.title, got, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckWindowExpires(t *testing.T) {
+	m, redis := newTestMuter(t)
+	check := func(title string, want State) {
+		t.Helper()
+		req := &notification.Request{Title: title, Level: notification.LevelError, Source: "backup-job"}
+		got, err := m.Check(context.Background(), req, "test", notification.DefaultLevels)
+		if err != nil {
+			t.Fatalf("Check: %v", err)
+		}
+		if got != want {
+			t.Errorf("%q: got state %d, want %d", title, got, want)
+		}
+	}
+
+	check("Disk 1", Allowed)
+	check("Disk 2", Allowed)
+	redis.FastForward(time.Minute)
+	check("Disk 3", Allowed)
+	check("Disk 4", Allowed)
+	check("Disk 5", Tripped)
+}
+
+// TestSummaryOfMute checks what a mute records for the summary
+func TestSummaryOfMute(t *testing.T) {
+	m, _ := newTestMuter(t)
+	ctx := context.Background()
+	send := func(title string, level notification.Level) {
+		t.Helper()
+		req := &notification.Request{Title: title, Level: level, Source: "backup-job"}
+		if _, err := m.Check(ctx, req, "test", notification.DefaultLevels); err != nil {
+			t.Fatalf("Check: %v", err)
+		}
+	}
+
+	for i := 1; i <= 3; i++ {
+		send("Disk "+strconv.Itoa(i), notification.LevelWarning)
+	}
+	send("Disk full", notification.LevelInfo)
+	send("Disk full", notification.LevelInfo)
+	send("Disk slow", notification.LevelError)
+
+	fields, err := unmute.Run(ctx, m.rdb, []string{m.muteKey("test", "backup-job")}).StringSlice()
+	if err != nil {
+		t.Fatalf("unmute: %v", err)
+	}
+	want := "Suppressed 3 notifications while muted for 15 minutes: 2 info, 1 error.\n\n" +
+		"Most frequent:\n2× Disk full\n1× Disk slow"
+	s := parseSummary(fields)
+	if got := s.message(15 * time.Minute); got != want {
+		t.Errorf("message:\n%s\nwant:\n%s", got, want)
+	}
+	if s.maxLevel != notification.LevelError {
+		t.Errorf("maxLevel = %s, want %s", s.maxLevel, notification.LevelError)
+	}
+}
+
+func TestSummaryMessage(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []string
+		want   string
+	}{
+		{
+			name:   "nothing suppressed",
+			fields: []string{"suppressed", "0"},
+			want:   "No notifications were suppressed while muted for 15 minutes.",
+		},
+		{
+			name:   "one notification",
+			fields: []string{"suppressed", "1", "level:info", "1", "max_level", "info", "titles", "1", "title:Disk full", "1"},
+			want:   "Suppressed 1 notification while muted for 15 minutes: 1 info.\n\nMost frequent:\n1× Disk full",
+		},
+		{
+			name: "most frequent first",
+			fields: []string{
+				"suppressed", "812",
+				"level:info", "790", "level:error", "22",
+				"max_severity", "40", "max_level", "error", "titles", "7",
+				"title:b", "3", "title:a", "3", "title:c", "400",
+				"title:d", "1", "title:e", "2", "title:f", "1", "title:g", "1",
+			},
+			want: "Suppressed 812 notifications while muted for 15 minutes: 790 info, 22 error.\n\n" +
+				"Most frequent:\n400× c\n3× a\n3× b\n2× e\n1× d",
+		},
+		{
+			name:   "no titles",
+			fields: []string{"suppressed", "2", "level:warning", "2"},
+			want:   "Suppressed 2 notifications while muted for 15 minutes: 2 warning.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseSummary(tt.fields).message(15 * time.Minute); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
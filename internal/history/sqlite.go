@@ -0,0 +1,328 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/luytbq/personal-notification-service/internal/notification"
+	_ "modernc.org/sqlite" // registers the "sqlite" driver
+)
+
+// Page size limits for List
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+// schema is applied on open; every statement is idempotent
+const schema = `
+CREATE TABLE IF NOT EXISTS notifications (
+	seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+	id         TEXT    NOT NULL UNIQUE,
+	request_id TEXT    NOT NULL DEFAULT '',
+	api_key_id TEXT    NOT NULL DEFAULT '',
+	channel    TEXT    NOT NULL,
+	title      TEXT    NOT NULL,
+	message    TEXT    NOT NULL,
+	level      TEXT    NOT NULL,
+	source     TEXT    NOT NULL DEFAULT '',
+	tags       TEXT    NOT NULL DEFAULT '[]',
+	priority   TEXT    NOT NULL DEFAULT '',
+	recipient  TEXT    NOT NULL DEFAULT '',
+	status     TEXT    NOT NULL,
+	last_error TEXT    NOT NULL DEFAULT '',
+	created_at INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS notifications_created_at ON notifications (created_at);
+CREATE INDEX IF NOT EXISTS notifications_source ON notifications (source, seq);
+CREATE INDEX IF NOT EXISTS notifications_status ON notifications (status, seq);
+CREATE INDEX IF NOT EXISTS notifications_channel ON notifications (channel, seq);
+
+CREATE TABLE IF NOT EXISTS delivery_attempts (
+	notification_id TEXT    NOT NULL REFERENCES notifications (id) ON DELETE CASCADE,
+	attempt         INTEGER NOT NULL,
+	error           TEXT    NOT NULL DEFAULT '',
+	latency_ms      INTEGER NOT NULL,
+	started_at      INTEGER NOT NULL,
+	PRIMARY KEY (notification_id, attempt)
+);
+
+CREATE VIRTUAL TABLE IF NOT EXISTS notifications_fts USING fts5 (
+	title, message, content = 'notifications', content_rowid = 'seq'
+);
+CREATE TRIGGER IF NOT EXISTS notifications_fts_insert AFTER INSERT ON notifications BEGIN
+	INSERT INTO notifications_fts (rowid, title, message) VALUES (new.seq, new.title, new.message);
+END;
+CREATE TRIGGER IF NOT EXISTS notifications_fts_delete AFTER DELETE ON notifications BEGIN
+	INSERT INTO notifications_fts (notifications_fts, rowid, title, message) VALUES ('delete', old.seq, old.title, old.message);
+END;
+`
+
+// SQLiteStore is a Store backed by a SQLite database file
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore opens (creating if needed) the database at path
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	// WAL lets the API read while the worker writes; the busy timeout makes
+	// concurrent writers wait instead of failing
+	dsn := "file:" + path + "?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database: %w", err)
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create history schema: %w", err)
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+// Close closes the database
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// Record stores a notification as queued
+func (s *SQLiteStore) Record(ctx context.Context, n *notification.Notification) error {
+	tags, err := json.Marshal(n.Tags)
+	if err != nil {
+		return fmt.Errorf("failed to encode tags: %w", err)
+	}
+	if n.Tags == nil {
+		tags = []byte("[]")
+	}
+
+	now := time.Now().UnixNano()
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO notifications (id, request_id, api_key_id, channel, title, message, level,
+			source, tags, priority, recipient, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		n.ID, n.RequestID, n.APIKeyID, string(n.Channel), n.Title, n.Message, string(n.Level),
+		n.Source, string(tags), string(n.Priority), n.Recipient, string(StatusQueued),
+		n.CreatedAt.UnixNano(), now,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record notification: %w", err)
+	}
+	return nil
+}
+
+// SetStatus sets the status of a notification
+func (s *SQLiteStore) SetStatus(ctx context.Context, id string, status Status, lastError string) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE notifications SET status = ?, last_error = ?, updated_at = ? WHERE id = ?`,
+		string(status), lastError, time.Now().UnixNano(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update notification status: %w", err)
+	}
+	return nil
+}
+
+// RecordAttempt stores a delivery attempt and the resulting status
+func (s *SQLiteStore) RecordAttempt(ctx context.Context, id string, a Attempt, status Status) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to record attempt: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE notifications SET status = ?, last_error = ?, updated_at = ? WHERE id = ?`,
+		string(status), a.Error, time.Now().UnixNano(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record attempt: %w", err)
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		// Enqueued before history was enabled, or pruned
+		return nil
+	}
+
+	// A redelivered attempt (e.g. after a worker crash) replaces the earlier row
+	_, err = tx.ExecContext(ctx, `
+		INSERT OR REPLACE INTO delivery_attempts (notification_id, attempt, error, latency_ms, started_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		id, a.Attempt, a.Error, a.LatencyMS, a.StartedAt.UnixNano(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record attempt: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to record attempt: %w", err)
+	}
+	return nil
+}
+
+// List returns notifications matching q, newest first. The cursor is the
+// sequence number of the last record of the previous page.
+func (s *SQLiteStore) List(ctx context.Context, q Query) (*Page, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	limit = min(limit, MaxLimit)
+
+	var (
+		where []string
+		args  []any
+	)
+	add := func(cond string, arg any) {
+		where = append(where, cond)
+		args = append(args, arg)
+	}
+	if q.Source != "" {
+		add("n.source = ?", q.Source)
+	}
+	if q.Level != "" {
+		add("n.level = ?", string(q.Level))
+	}
+	if q.Channel != "" {
+		add("n.channel = ?", string(q.Channel))
+	}
+	if q.Status != "" {
+		add("n.status = ?", string(q.Status))
+	}
+	if q.APIKeyID != "" {
+		add("n.api_key_id = ?", q.APIKeyID)
+	}
+	if !q.Since.IsZero() {
+		add("n.created_at >= ?", q.Since.UnixNano())
+	}
+	if !q.Until.IsZero() {
+		add("n.created_at < ?", q.Until.UnixNano())
+	}
+	if strings.TrimSpace(q.Text) != "" {
+		add("n.seq IN (SELECT rowid FROM notifications_fts WHERE notifications_fts MATCH ?)", ftsQuery(q.Text))
+	}
+	if q.Cursor != "" {
+		seq, err := strconv.ParseInt(q.Cursor, 10, 64)
+		if err != nil || seq <= 0 {
+			return nil, ErrInvalidCursor
+		}
+		add("n.seq < ?", seq)
+	}
+
+	query := `SELECT n.seq, n.id, n.request_id, n.api_key_id, n.channel, n.title, n.message, n.level,
+		n.source, n.tags, n.priority, n.recipient, n.status, n.last_error, n.created_at, n.updated_at
+		FROM notifications n`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	// Fetch one extra row to know whether there is a next page
+	query += " ORDER BY n.seq DESC LIMIT ?"
+	args = append(args, limit+1)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history: %w", err)
+	}
+	defer rows.Close()
+
+	page := &Page{Records: []Record{}}
+	var lastSeq int64
+	for rows.Next() {
+		if len(page.Records) == limit {
+			page.NextCursor = strconv.FormatInt(lastSeq, 10)
+			break
+		}
+		var (
+			r                    Record
+			channel, level, prio string
+			status, tags         string
+			created, updated     int64
+		)
+		err := rows.Scan(&lastSeq, &r.ID, &r.RequestID, &r.APIKeyID, &channel, &r.Title, &r.Message, &level,
+			&r.Source, &tags, &prio, &r.Recipient, &status, &r.LastError, &created, &updated)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history: %w", err)
+		}
+		r.Channel = notification.Channel(channel)
+		r.Level = notification.Level(level)
+		r.Priority = notification.Priority(prio)
+		r.Status = Status(status)
+		r.CreatedAt = time.Unix(0, created).UTC()
+		r.UpdatedAt = time.Unix(0, updated).UTC()
+		if err := json.Unmarshal([]byte(tags), &r.Tags); err != nil {
+			return nil, fmt.Errorf("failed to decode tags: %w", err)
+		}
+		r.Attempts = []Attempt{}
+		page.Records = append(page.Records, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	rows.Close()
+
+	if err := s.loadAttempts(ctx, page.Records); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// loadAttempts fills in the delivery attempts of records
+func (s *SQLiteStore) loadAttempts(ctx context.Context, records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	byID := make(map[string]*Record, len(records))
+	args := make([]any, 0, len(records))
+	for i := range records {
+		byID[records[i].ID] = &records[i]
+		args = append(args, records[i].ID)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT notification_id, attempt, error, latency_ms, started_at FROM delivery_attempts
+		WHERE notification_id IN (?`+strings.Repeat(", ?", len(args)-1)+`)
+		ORDER BY notification_id, attempt`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query delivery attempts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id      string
+			a       Attempt
+			started int64
+		)
+		if err := rows.Scan(&id, &a.Attempt, &a.Error, &a.LatencyMS, &started); err != nil {
+			return fmt.Errorf("failed to read delivery attempts: %w", err)
+		}
+		a.StartedAt = time.Unix(0, started).UTC()
+		if r, ok := byID[id]; ok {
+			r.Attempts = append(r.Attempts, a)
+		}
+	}
+	return rows.Err()
+}
+
+// Prune deletes notifications created before t, with their attempts
+func (s *SQLiteStore) Prune(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM notifications WHERE created_at < ?`, before.UnixNano())
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune history: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// ftsQuery turns free text into an FTS5 query matching all words, so that
+// user input cannot use (or break on) FTS5 query syntax
+func ftsQuery(text string) string {
+	words := strings.Fields(text)
+	for i, w := range words {
+		words[i] = `"` + strings.ReplaceAll(w, `"`, `""`) + `"`
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,118 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/luytbq/personal-notification-service/internal/notification"
+)
+
+// Status is the delivery status of a notification
+type Status string
+
+const (
+	StatusQueued   Status = "queued"   // waiting for the worker
+	StatusRetrying Status = "retrying" // an attempt failed, another is scheduled
+	StatusSent     Status = "sent"     // delivered
+	StatusFailed   Status = "failed"   // gave up, or could not be enqueued
+	StatusSilenced Status = "silenced" // matched a silence and was not sent
+)
+
+// Statuses lists every known status
+var Statuses = []Status{StatusQueued, StatusRetrying, StatusSent, StatusFailed, StatusSilenced}
+
+// IsValid checks if the status is known
+func (s Status) IsValid() bool {
+	return slices.Contains(Statuses, s)
+}
+
+// ErrInvalidCursor is returned for a malformed pagination cursor
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Attempt is one delivery attempt of a notification
+type Attempt struct {
+	Attempt   int       `json:"attempt"` // 1 for the first try
+	Error     string    `json:"error,omitempty"`
+	LatencyMS int64     `json:"latency_ms"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// Record is a notification with its delivery history
+type Record struct {
+	notification.Notification
+	Status    Status    `json:"status"`
+	LastError string    `json:"last_error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Attempts  []Attempt `json:"attempts"`
+}
+
+// Query filters and paginates history. Empty fields match everything.
+type Query struct {
+	Source   string
+	Level    notification.Level
+	Channel  notification.Channel
+	Status   Status
+	APIKeyID string
+	Since    time.Time // inclusive
+	Until    time.Time // exclusive
+	Text     string    // full-text search over title and message
+	Cursor   string    // NextCursor of the previous page
+	Limit    int
+}
+
+// Page is one page of query results, newest first
+type Page struct {
+	Records    []Record `json:"notifications"`
+	NextCursor string   `json:"next_cursor,omitempty"` // empty on the last page
+}
+
+// Store persists notification history. Implementations must be safe for
+// concurrent use by the API and the worker.
+type Store interface {
+	// Record stores a notification as queued, before it is enqueued
+	Record(ctx context.Context, n *notification.Notification) error
+
+	// SetStatus sets the status of a notification without an attempt,
+	// e.g. when it could not be enqueued
+	SetStatus(ctx context.Context, id string, status Status, lastError string) error
+
+	// RecordAttempt stores a delivery attempt and the resulting status
+	RecordAttempt(ctx context.Context, id string, a Attempt, status Status) error
+
+	// List returns notifications matching q, newest first
+	List(ctx context.Context, q Query) (*Page, error)
+
+	// Prune deletes notifications created before t and returns how many
+	Prune(ctx context.Context, before time.Time) (int64, error)
+
+	// Close releases the store's resources
+	Close() error
+}
+
+// pruneInterval is how often RunPruner deletes expired history
+const pruneInterval = time.Hour
+
+// RunPruner deletes notifications older than retention every hour, until ctx
+// is done
+func RunPruner(ctx context.Context, store Store, retention time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := store.Prune(ctx, time.Now().Add(-retention))
+		if err != nil {
+			logger.Error("failed to prune notification history", slog.String("error", err.Error()))
+		} else if deleted > 0 {
+			logger.Info("pruned notification history", slog.Int64("deleted", deleted))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
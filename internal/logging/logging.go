@@ -0,0 +1,208 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Components that can be given their own log level
+const (
+	ComponentAPI      = "api"
+	ComponentWorker   = "worker"
+	ComponentChannels = "channels"
+)
+
+// Components lists every component with its own log level
+var Components = []string{ComponentAPI, ComponentWorker, ComponentChannels}
+
+// Log formats
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// ParseLevel parses a level name (debug, info, warn, error)
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q: use debug, info, warn or error", s)
+	}
+	return level, nil
+}
+
+// IsComponent reports whether name is a known component
+func IsComponent(name string) bool {
+	return slices.Contains(Components, name)
+}
+
+// Levels holds the default log level and per-component overrides. Levels can
+// be changed at any time; loggers created by Logger pick changes up at once.
+type Levels struct {
+	mu        sync.RWMutex
+	base      slog.Level
+	overrides map[string]slog.Level
+}
+
+// Level returns the effective level of a component, or the default level
+// for an empty component
+func (l *Levels) Level(component string) slog.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if level, ok := l.overrides[component]; ok {
+		return level
+	}
+	return l.base
+}
+
+// SetLevel sets the default level, or a component's level when component is
+// not empty
+func (l *Levels) SetLevel(component string, level slog.Level) error {
+	if component != "" && !IsComponent(component) {
+		return fmt.Errorf("unknown log component %q: use one of %s", component, strings.Join(Components, ", "))
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if component == "" {
+		l.base = level
+		return nil
+	}
+	if l.overrides == nil {
+		l.overrides = make(map[string]slog.Level)
+	}
+	l.overrides[component] = level
+	return nil
+}
+
+// ResetLevel makes a component follow the default level again
+func (l *Levels) ResetLevel(component string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.overrides, component)
+}
+
+// Apply replaces the default level and all overrides, as configured by
+// server.log_level and server.log_levels
+func (l *Levels) Apply(level string, components map[string]string) error {
+	base, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	overrides := make(map[string]slog.Level, len(components))
+	for name, s := range components {
+		if !IsComponent(name) {
+			return fmt.Errorf("unknown log component %q: use one of %s", name, strings.Join(Components, ", "))
+		}
+		if overrides[name], err = ParseLevel(s); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.base = base
+	l.overrides = overrides
+	return nil
+}
+
+// Snapshot returns the default level and the effective level of every component
+func (l *Levels) Snapshot() (string, map[string]string) {
+	components := make(map[string]string, len(Components))
+	for _, name := range Components {
+		components[name] = strings.ToLower(l.Level(name).String())
+	}
+	return strings.ToLower(l.Level("").String()), components
+}
+
+// Logging builds the service's loggers from one output and format
+type Logging struct {
+	Levels  *Levels
+	handler slog.Handler
+	closer  io.Closer
+}
+
+// New creates loggers writing to output ("stdout", "stderr" or a file path,
+// appended to) in the given format ("json" or "text")
+func New(format, output string, levels *Levels) (*Logging, error) {
+	var (
+		w      io.Writer
+		closer io.Closer
+	)
+	switch output {
+	case "", "stdout":
+		w = os.Stdout
+	case "stderr":
+		w = os.Stderr
+	default:
+		f, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		w, closer = f, f
+	}
+
+	// Filtering is done per component by componentHandler
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	var handler slog.Handler
+	switch format {
+	case "", FormatJSON:
+		handler = slog.NewJSONHandler(w, opts)
+	case FormatText:
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		if closer != nil {
+			closer.Close()
+		}
+		return nil, fmt.Errorf("invalid log format %q: use json or text", format)
+	}
+
+	return &Logging{Levels: levels, handler: handler, closer: closer}, nil
+}
+
+// Logger returns the default logger, filtered by the default level
+func (l *Logging) Logger() *slog.Logger {
+	return slog.New(&componentHandler{Handler: l.handler, levels: l.Levels})
+}
+
+// For returns a component's logger, filtered by the component's level and
+// tagging every record with the component name
+func (l *Logging) For(component string) *slog.Logger {
+	return slog.New(&componentHandler{
+		Handler:   l.handler.WithAttrs([]slog.Attr{slog.String("component", component)}),
+		levels:    l.Levels,
+		component: component,
+	})
+}
+
+// Close closes the log file, if any
+func (l *Logging) Close() error {
+	if l.closer == nil {
+		return nil
+	}
+	return l.closer.Close()
+}
+
+// componentHandler filters records by the current level of its component
+type componentHandler struct {
+	slog.Handler
+	levels    *Levels
+	component string
+}
+
+func (h *componentHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.levels.Level(h.component)
+}
+
+func (h *componentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &componentHandler{Handler: h.Handler.WithAttrs(attrs), levels: h.levels, component: h.component}
+}
+
+func (h *componentHandler) WithGroup(name string) slog.Handler {
+	return &componentHandler{Handler: h.Handler.WithGroup(name), levels: h.levels, component: h.component}
+}
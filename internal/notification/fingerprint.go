@@ -0,0 +1,55 @@
+package notification
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+)
+
+// FingerprintFields are the request fields a fingerprint can be computed from
+var FingerprintFields = map[string]bool{
+	"source":  true,
+	"title":   true,
+	"level":   true,
+	"message": true,
+	"tags":    true,
+	"channel": true,
+}
+
+// FingerprintOf identifies repeats of a request: the caller's fingerprint if
+// it has one, otherwise a hash of the given fields
+func FingerprintOf(req *Request, fields []string) string {
+	if req.Fingerprint != "" {
+		return req.Fingerprint
+	}
+
+	h := sha256.New()
+	for _, f := range fields {
+		var value string
+		switch f {
+		case "source":
+			value = req.Source
+		case "title":
+			value = req.Title
+		case "level":
+			value = string(req.Level)
+		case "message":
+			value = req.Message
+		case "tags":
+			tags := append([]string(nil), req.Tags...)
+			sort.Strings(tags)
+			value = strings.Join(tags, ",")
+		case "channel":
+			names := make([]string, len(req.Channels))
+			for i, ch := range req.Channels {
+				names[i] = string(ch)
+			}
+			sort.Strings(names)
+			value = strings.Join(names, ",")
+		}
+		// The separator keeps "ab"+"c" apart from "a"+"bc"
+		h.Write([]byte(f + "=" + value + "\x00"))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
@@ -0,0 +1,394 @@
+package notification
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Markup is the markup language a formatter renders to
+type Markup string
+
+const (
+	MarkupText     Markup = "text"     // plain text
+	MarkupMarkdown Markup = "markdown" // Telegram MarkdownV2
+	MarkupHTML     Markup = "html"     // the HTML subset Telegram accepts
+)
+
+// IsValid reports whether m is a known markup
+func (m Markup) IsValid() bool {
+	switch m {
+	case MarkupText, MarkupMarkdown, MarkupHTML:
+		return true
+	}
+	return false
+}
+
+// DefaultTimestampFormat is the Go time layout used when none is configured.
+// It ends with the zone so readers in another zone are not misled.
+const DefaultTimestampFormat = "2006-01-02 15:04:05 MST"
+
+// Locale selects the language of labels and relative times
+type Locale string
+
+const (
+	LocaleEnglish    Locale = "en"
+	LocaleVietnamese Locale = "vi"
+)
+
+// IsValid reports whether l is a known locale
+func (l Locale) IsValid() bool {
+	_, ok := locales[l]
+	return ok
+}
+
+// FormatOptions controls how a notification is rendered
+type FormatOptions struct {
+	Markup          Markup
+	Location        *time.Location   // nil means the server's local zone
+	TimestampFormat string           // Go time layout
+	Locale          Locale           // defaults to English
+	Levels          *Levels          // defaults to DefaultLevels
+	LevelPrefixes   map[Level]string // replaces the level's emoji and prefix
+	ShowSource      bool
+	// DelayThreshold is how late a delivery must be, e.g. after retries, for
+	// the timestamp to show how long ago the event happened. 0 never shows it.
+	DelayThreshold time.Duration
+	Now            func() time.Time // defaults to time.Now
+}
+
+// Formatter turns a notification into channel text
+type Formatter interface {
+	// Markup returns the markup of the rendered text
+	Markup() Markup
+	// Render returns the notification as text
+	Render(n *Notification) string
+	// Level returns the definition of a level, e.g. for its color
+	Level(l Level) LevelDef
+}
+
+// NewFormatter creates a Formatter. Unknown markups render plain text.
+func NewFormatter(opts FormatOptions) Formatter {
+	if opts.Location == nil {
+		opts.Location = time.Local
+	}
+	if opts.TimestampFormat == "" {
+		opts.TimestampFormat = DefaultTimestampFormat
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	if opts.Levels == nil {
+		opts.Levels = DefaultLevels
+	}
+	text, ok := locales[opts.Locale]
+	if !ok {
+		text = locales[LocaleEnglish]
+	}
+
+	f := &formatter{opts: opts, text: text, escape: func(s string) string { return s }}
+	switch opts.Markup {
+	case MarkupMarkdown:
+		f.escape = escapeMarkdown
+		f.bold = "*%s*"
+		f.italic = "_%s_"
+	case MarkupHTML:
+		f.escape = html.EscapeString
+		f.bold = "<b>%s</b>"
+		f.italic = "<i>%s</i>"
+	default:
+		f.opts.Markup = MarkupText
+	}
+	return f
+}
+
+// DefaultFormatter renders plain text with the default options
+var DefaultFormatter = NewFormatter(FormatOptions{ShowSource: true})
+
+// formatter lays out every markup the same way; only escaping and emphasis differ
+type formatter struct {
+	opts         FormatOptions
+	text         *localeText
+	escape       func(string) string
+	bold, italic string // format strings; empty for no emphasis
+}
+
+// Markup returns the markup of the rendered text
+func (f *formatter) Markup() Markup {
+	return f.opts.Markup
+}
+
+// Render returns the notification as
+//
+//	<prefix> <title>
+//
+//	<message>
+//
+//	Source: <source>
+//	Timestamp: <created at> (<time since created at>)
+//
+// The time since creation is only shown for late deliveries.
+func (f *formatter) Render(n *Notification) string {
+	if len(n.Digest) > 0 {
+		return f.renderDigest(n)
+	}
+
+	var b strings.Builder
+
+	b.WriteString(f.emphasize(f.bold, f.levelPrefix(n.Level)+" "+n.Title))
+	b.WriteString("\n\n")
+	b.WriteString(f.escape(n.Message))
+
+	if f.opts.ShowSource && n.Source != "" {
+		b.WriteString("\n\n")
+		b.WriteString(f.emphasize(f.italic, f.text.source+": "+n.Source))
+	}
+	b.WriteString("\n")
+	b.WriteString(f.emphasize(f.italic, f.text.timestamp+": "+f.timestamp(n.CreatedAt)))
+
+	return b.String()
+}
+
+// Level returns the definition of a level
+func (f *formatter) Level(l Level) LevelDef {
+	return f.opts.Levels.Get(l)
+}
+
+// Digest layout limits, so that a digest fits in one message
+const (
+	digestItemsPerGroup = 5
+	digestTitleLength   = 80
+)
+
+// digestGroup is the notifications of one source and level in a digest
+type digestGroup struct {
+	source string
+	level  Level
+	items  []*Notification
+}
+
+// renderDigest returns a digest as
+//
+//	<prefix> <count> notifications
+//
+//	<source> · <prefix> (<count>)
+//	• <time> <title>
+//	…
+//
+//	Timestamp: <first created at> – <last created at>
+//
+// Groups are ordered from most to least severe, then by source.
+func (f *formatter) renderDigest(n *Notification) string {
+	var groups []*digestGroup
+	byKey := make(map[string]*digestGroup)
+	first, last := n.Digest[0].CreatedAt, n.Digest[0].CreatedAt
+	for _, item := range n.Digest {
+		key := string(item.Level) + "\x00" + item.Source
+		g, ok := byKey[key]
+		if !ok {
+			g = &digestGroup{source: item.Source, level: item.Level}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.items = append(g.items, item)
+		if item.CreatedAt.Before(first) {
+			first = item.CreatedAt
+		}
+		if item.CreatedAt.After(last) {
+			last = item.CreatedAt
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		si, sj := f.opts.Levels.Severity(groups[i].level), f.opts.Levels.Severity(groups[j].level)
+		if si != sj {
+			return si > sj
+		}
+		return groups[i].source < groups[j].source
+	})
+
+	var b strings.Builder
+	heading := fmt.Sprintf(f.text.digestFormat, len(n.Digest))
+	if len(n.Digest) == 1 {
+		heading = f.text.digestOne
+	}
+	b.WriteString(f.emphasize(f.bold, f.levelPrefix(n.Level)+" "+heading))
+
+	for _, g := range groups {
+		source := g.source
+		if source == "" {
+			source = f.text.noSource
+		}
+		b.WriteString("\n\n")
+		b.WriteString(f.emphasize(f.bold, fmt.Sprintf("%s · %s (%d)", source, f.levelPrefix(g.level), len(g.items))))
+		for i, item := range g.items {
+			if i == digestItemsPerGroup {
+				b.WriteString("\n")
+				b.WriteString(f.escape(fmt.Sprintf(f.text.moreFormat, len(g.items)-i)))
+				break
+			}
+			b.WriteString("\n")
+			b.WriteString(f.escape("• " + item.CreatedAt.In(f.opts.Location).Format("15:04") + " " + truncate(item.Title, digestTitleLength)))
+		}
+	}
+
+	b.WriteString("\n\n")
+	period := first.In(f.opts.Location).Format(f.opts.TimestampFormat)
+	if last.After(first) {
+		period += " – " + last.In(f.opts.Location).Format(f.opts.TimestampFormat)
+	}
+	b.WriteString(f.emphasize(f.italic, f.text.timestamp+": "+period))
+
+	return b.String()
+}
+
+// truncate shortens s to at most n characters, ending with an ellipsis
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// levelPrefix returns the configured prefix for a level
+func (f *formatter) levelPrefix(l Level) string {
+	if p, ok := f.opts.LevelPrefixes[l]; ok {
+		return p
+	}
+	return f.opts.Levels.Get(l).Label()
+}
+
+// timestamp formats t in the configured zone and layout, followed by how long
+// ago it was when that exceeds the delay threshold
+func (f *formatter) timestamp(t time.Time) string {
+	s := t.In(f.opts.Location).Format(f.opts.TimestampFormat)
+	if delay := f.opts.Now().Sub(t); f.opts.DelayThreshold > 0 && delay >= f.opts.DelayThreshold {
+		s += " (" + f.text.ago(delay) + ")"
+	}
+	return s
+}
+
+// localeText holds the words of one locale
+type localeText struct {
+	source, timestamp         string
+	second, minute, hour, day string
+	agoFormat                 string // wraps the duration, e.g. "%s ago"
+	digestFormat              string // digest heading, e.g. "%d notifications"
+	digestOne                 string // digest heading for a single item
+	moreFormat                string // items left out of a digest group
+	noSource                  string
+}
+
+var locales = map[Locale]*localeText{
+	LocaleEnglish: {
+		source: "Source", timestamp: "Timestamp",
+		second: "sec", minute: "min", hour: "h", day: "d",
+		agoFormat:    "%s ago",
+		digestFormat: "%d notifications", digestOne: "1 notification", moreFormat: "… and %d more", noSource: "no source",
+	},
+	LocaleVietnamese: {
+		source: "Nguồn", timestamp: "Thời gian",
+		second: "giây", minute: "phút", hour: "giờ", day: "ngày",
+		agoFormat:    "%s trước",
+		digestFormat: "%d thông báo", digestOne: "1 thông báo", moreFormat: "… và %d thông báo khác", noSource: "không rõ nguồn",
+	},
+}
+
+// ago renders a duration as a relative time with its two largest units,
+// e.g. "3 min ago" or "1 d 4 h ago"
+func (l *localeText) ago(d time.Duration) string {
+	units := []struct {
+		size time.Duration
+		name string
+	}{
+		{24 * time.Hour, l.day}, {time.Hour, l.hour}, {time.Minute, l.minute}, {time.Second, l.second},
+	}
+
+	var parts []string
+	for _, u := range units {
+		if n := d / u.size; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, u.name))
+			d -= n * u.size
+		} else if len(parts) > 0 {
+			break // skip "1 h 0 min"
+		}
+		if len(parts) == 2 {
+			break
+		}
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "0 "+l.second)
+	}
+	return fmt.Sprintf(l.agoFormat, strings.Join(parts, " "))
+}
+
+// DescribeDuration renders a duration in English words with its largest
+// whole unit, e.g. "10 minutes", for server-generated messages
+func DescribeDuration(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		n := int(d / time.Hour)
+		return fmt.Sprintf("%d %s", n, Plural(n, "hour", "hours"))
+	case d%time.Minute == 0:
+		n := int(d / time.Minute)
+		return fmt.Sprintf("%d %s", n, Plural(n, "minute", "minutes"))
+	default:
+		n := int(d.Round(time.Second) / time.Second)
+		return fmt.Sprintf("%d %s", n, Plural(n, "second", "seconds"))
+	}
+}
+
+// Plural returns one if n is 1, otherwise many
+func Plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+// emphasize escapes s and wraps it with the given format, if any
+func (f *formatter) emphasize(format, s string) string {
+	if format == "" {
+		return f.escape(s)
+	}
+	return fmt.Sprintf(format, f.escape(s))
+}
+
+// markdownReplacer escapes the characters Telegram's MarkdownV2 reserves
+var markdownReplacer = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+	"~", `\~`, "`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`,
+	"|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+func escapeMarkdown(s string) string {
+	return markdownReplacer.Replace(s)
+}
+
+// Formatters selects the formatter of each channel
+type Formatters struct {
+	def      Formatter
+	channels map[Channel]Formatter
+}
+
+// NewFormatters creates a set using def for channels without their own options
+func NewFormatters(def FormatOptions, channels map[Channel]FormatOptions) *Formatters {
+	f := &Formatters{
+		def:      NewFormatter(def),
+		channels: make(map[Channel]Formatter, len(channels)),
+	}
+	for ch, opts := range channels {
+		f.channels[ch] = NewFormatter(opts)
+	}
+	return f
+}
+
+// For returns the formatter of a channel
+func (f *Formatters) For(ch Channel) Formatter {
+	if formatter, ok := f.channels[ch]; ok {
+		return formatter
+	}
+	return f.def
+}
@@ -0,0 +1,199 @@
+package notification
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// assertGolden compares got with testdata/<name>.golden, or rewrites the file
+// when the test runs with -update
+func assertGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatalf("failed to update %s: %v", path, err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v (run with -update to create it)", path, err)
+	}
+	if got != string(want) {
+		t.Errorf("%s does not match\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// testCreatedAt is when the test notifications were created
+var testCreatedAt = time.Date(2026, 3, 14, 9, 26, 53, 0, time.UTC)
+
+func testNotification() *Notification {
+	return &Notification{
+		ID:        "3f0c2a9e-6a1d-4a43-9d55-0b8a6b1f1c2e",
+		Title:     "Backup failed (nightly_db)",
+		Message:   "Disk /var is 98% full; see https://ops.example.com/runbook?id=7 & retry.",
+		Level:     LevelError,
+		Channel:   ChannelTelegram,
+		APIKeyID:  "ops",
+		CreatedAt: testCreatedAt,
+		Source:    "backup-job",
+		Tags:      []string{"db", "nightly"},
+	}
+}
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s is not available: %v", name, err)
+	}
+	return loc
+}
+
+func TestRenderGolden(t *testing.T) {
+	saigon := mustLoadLocation(t, "Asia/Ho_Chi_Minh")
+	customLevels, err := NewLevels([]LevelDef{
+		{Name: LevelInfo, Severity: 20, Prefix: "[INFO]", Priority: PriorityNormal},
+		{Name: LevelWarning, Severity: 30, Prefix: "[WARN]", Emoji: "🟡", Priority: PriorityNormal},
+		{Name: "notice", Severity: 25, Prefix: "[NOTICE]", Emoji: "📣", Priority: PriorityLow},
+		{Name: LevelError, Severity: 40, Prefix: "[ERR]", Emoji: "🔴", Priority: PriorityNormal},
+		{Name: LevelCritical, Severity: 50, Prefix: "[CRIT]", Emoji: "🚨", Priority: PriorityHigh},
+	})
+	if err != nil {
+		t.Fatalf("NewLevels: %v", err)
+	}
+	late := func() time.Time { return testCreatedAt.Add(26*time.Hour + 4*time.Minute) }
+
+	tests := []struct {
+		name   string
+		opts   FormatOptions
+		modify func(n *Notification)
+	}{
+		{name: "text", opts: FormatOptions{Markup: MarkupText, Location: time.UTC, ShowSource: true}},
+		{name: "markdown", opts: FormatOptions{Markup: MarkupMarkdown, Location: time.UTC, ShowSource: true}},
+		{name: "html", opts: FormatOptions{Markup: MarkupHTML, Location: time.UTC, ShowSource: true}},
+		{name: "unknown_markup", opts: FormatOptions{Markup: "bbcode", Location: time.UTC, ShowSource: true}},
+		{name: "no_source", opts: FormatOptions{Markup: MarkupText, Location: time.UTC}},
+		{
+			name:   "empty_source",
+			opts:   FormatOptions{Markup: MarkupText, Location: time.UTC, ShowSource: true},
+			modify: func(n *Notification) { n.Source = "" },
+		},
+		{name: "timezone", opts: FormatOptions{Markup: MarkupText, Location: saigon, ShowSource: true}},
+		{
+			name: "timestamp_format",
+			opts: FormatOptions{Markup: MarkupMarkdown, Location: saigon, TimestampFormat: "02/01/2006 15:04 -07:00", ShowSource: true},
+		},
+		{
+			name: "level_prefixes",
+			opts: FormatOptions{
+				Markup:        MarkupHTML,
+				Location:      time.UTC,
+				LevelPrefixes: map[Level]string{LevelError: "🔥 ERR"},
+				ShowSource:    true,
+			},
+		},
+		{
+			name:   "unknown_level",
+			opts:   FormatOptions{Markup: MarkupText, Location: time.UTC, ShowSource: true},
+			modify: func(n *Notification) { n.Level = "debug" },
+		},
+		{name: "level_labels", opts: FormatOptions{Markup: MarkupText, Location: time.UTC, Levels: customLevels, ShowSource: true}},
+		{
+			name:   "custom_level",
+			opts:   FormatOptions{Markup: MarkupMarkdown, Location: time.UTC, Levels: customLevels, ShowSource: true},
+			modify: func(n *Notification) { n.Level = "notice" },
+		},
+		{
+			name: "delay",
+			opts: FormatOptions{Markup: MarkupText, Location: time.UTC, ShowSource: true, DelayThreshold: time.Minute, Now: late},
+		},
+		{
+			name: "delay_below_threshold",
+			opts: FormatOptions{Markup: MarkupText, Location: time.UTC, ShowSource: true, DelayThreshold: 48 * time.Hour, Now: late},
+		},
+		{
+			name: "locale_vi",
+			opts: FormatOptions{
+				Markup: MarkupHTML, Location: saigon, Locale: LocaleVietnamese,
+				ShowSource: true, DelayThreshold: time.Minute, Now: late,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := testNotification()
+			if tt.modify != nil {
+				tt.modify(n)
+			}
+			assertGolden(t, "render_"+tt.name, NewFormatter(tt.opts).Render(n))
+		})
+	}
+}
+
+// testDigest returns a digest of items from two sources at three levels,
+// with more errors from one source than a group shows
+func testDigest() *Notification {
+	var items []*Notification
+	add := func(source string, level Level, title string, minutes int) {
+		items = append(items, &Notification{
+			Title:     title,
+			Message:   "details",
+			Level:     level,
+			Source:    source,
+			CreatedAt: testCreatedAt.Add(time.Duration(minutes) * time.Minute),
+		})
+	}
+	add("backup-job", LevelWarning, "Backup slow", 0)
+	for i := 1; i <= 7; i++ {
+		add("backup-job", LevelError, fmt.Sprintf("Backup failed (attempt %d)", i), i)
+	}
+	add("", LevelInfo, "Certificate renewed for *.example.com", 3)
+	add("deploy", LevelError, "Rollout stalled: api-gateway has 3 of 12 replicas ready after 10 minutes; pausing the rollout until a human looks", 12)
+
+	return &Notification{
+		ID:        "digest-1",
+		Title:     "10 notifications",
+		Level:     LevelError,
+		Channel:   ChannelTelegram,
+		CreatedAt: testCreatedAt.Add(15 * time.Minute),
+		Digest:    items,
+	}
+}
+
+func TestRenderDigestGolden(t *testing.T) {
+	saigon := mustLoadLocation(t, "Asia/Ho_Chi_Minh")
+
+	tests := []struct {
+		name string
+		opts FormatOptions
+	}{
+		{name: "text", opts: FormatOptions{Markup: MarkupText, Location: time.UTC}},
+		{name: "markdown", opts: FormatOptions{Markup: MarkupMarkdown, Location: time.UTC}},
+		{name: "html", opts: FormatOptions{Markup: MarkupHTML, Location: time.UTC}},
+		{name: "locale_vi", opts: FormatOptions{Markup: MarkupText, Location: saigon, Locale: LocaleVietnamese}},
+		{
+			name: "level_prefixes",
+			opts: FormatOptions{Markup: MarkupText, Location: time.UTC, LevelPrefixes: map[Level]string{LevelError: "ERR"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertGolden(t, "digest_"+tt.name, NewFormatter(tt.opts).Render(testDigest()))
+		})
+	}
+
+	t.Run("single_item", func(t *testing.T) {
+		n := testDigest()
+		n.Digest = n.Digest[:1]
+		n.Level = LevelWarning
+		assertGolden(t, "digest_single_item", NewFormatter(FormatOptions{Markup: MarkupText, Location: time.UTC}).Render(n))
+	})
+}
@@ -0,0 +1,89 @@
+package notification
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ChannelGroupPrefix prefixes channel names that refer to a channel group
+const ChannelGroupPrefix = "group:"
+
+// Groups maps group channel names (e.g. "group:oncall") to their member channels
+type Groups map[Channel][]Channel
+
+// NewGroups builds Groups from a config map of group name to member channels.
+// Members must be concrete channels; groups cannot be nested.
+func NewGroups(defs map[string][]string) (Groups, error) {
+	groups := make(Groups, len(defs))
+	for name, members := range defs {
+		if name == "" {
+			return nil, fmt.Errorf("channel group name must not be empty")
+		}
+		if len(members) == 0 {
+			return nil, fmt.Errorf("channel group %q: at least one channel is required", name)
+		}
+
+		channels := make([]Channel, 0, len(members))
+		for _, m := range members {
+			ch := Channel(m)
+			if ch.IsGroup() {
+				return nil, fmt.Errorf("channel group %q: nested group %q is not supported", name, m)
+			}
+			if !ch.IsValid() {
+				return nil, fmt.Errorf("channel group %q: invalid channel %q", name, m)
+			}
+			channels = append(channels, ch)
+		}
+		groups[Channel(ChannelGroupPrefix+name)] = channels
+	}
+	return groups, nil
+}
+
+// IsGroup reports whether c refers to a channel group
+func (c Channel) IsGroup() bool {
+	return strings.HasPrefix(string(c), ChannelGroupPrefix)
+}
+
+// Has reports whether the group channel is defined
+func (g Groups) Has(c Channel) bool {
+	_, ok := g[c]
+	return ok
+}
+
+// Names returns the sorted group channel names
+func (g Groups) Names() []Channel {
+	names := make([]Channel, 0, len(g))
+	for name := range g {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+// Expand replaces group channels with their members and removes duplicates,
+// keeping the order in which channels first appear. Unknown groups are kept
+// as-is so the caller can report them.
+func (g Groups) Expand(channels []Channel) []Channel {
+	result := make([]Channel, 0, len(channels))
+	seen := make(map[Channel]bool, len(channels))
+
+	add := func(ch Channel) {
+		if !seen[ch] {
+			seen[ch] = true
+			result = append(result, ch)
+		}
+	}
+
+	for _, ch := range channels {
+		members, ok := g[ch]
+		if !ok {
+			add(ch)
+			continue
+		}
+		for _, m := range members {
+			add(m)
+		}
+	}
+	return result
+}
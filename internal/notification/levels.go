@@ -0,0 +1,140 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// LevelDef describes how a level ranks and how it is presented
+type LevelDef struct {
+	Name     Level
+	Severity int      // higher is more severe
+	Prefix   string   // e.g. "[ERROR]"
+	Emoji    string   // shown before the prefix
+	Color    string   // "#rrggbb", for channels and UIs that support color
+	Priority Priority // queue priority when the request does not set one
+}
+
+// Label returns the emoji and prefix shown before the title
+func (d LevelDef) Label() string {
+	return strings.TrimSpace(d.Emoji + " " + d.Prefix)
+}
+
+// BuiltinLevels are always defined. Configuration may change their
+// presentation and add levels in between.
+var BuiltinLevels = []LevelDef{
+	{Name: LevelInfo, Severity: 20, Prefix: "[INFO]", Color: "#1e88e5", Priority: PriorityNormal},
+	{Name: LevelWarning, Severity: 30, Prefix: "[WARNING]", Color: "#f9a825", Priority: PriorityNormal},
+	{Name: LevelError, Severity: 40, Prefix: "[ERROR]", Color: "#e53935", Priority: PriorityNormal},
+	{Name: LevelCritical, Severity: 50, Prefix: "[CRITICAL]", Color: "#b71c1c", Priority: PriorityNormal},
+}
+
+// Levels is the set of known levels, ordered by severity
+type Levels struct {
+	defs  map[Level]LevelDef
+	names []Level
+}
+
+// NewLevels validates the level definitions and builds the set. Every
+// built-in level must be included.
+func NewLevels(defs []LevelDef) (*Levels, error) {
+	ls := &Levels{defs: make(map[Level]LevelDef, len(defs))}
+	severities := make(map[int]Level, len(defs))
+
+	for _, d := range defs {
+		switch {
+		case d.Name == "":
+			return nil, errors.New("level name must not be empty")
+		case strings.ContainsAny(string(d.Name), " ,"):
+			return nil, fmt.Errorf("level %s: name must not contain spaces or commas", d.Name)
+		case d.Severity <= 0:
+			return nil, fmt.Errorf("level %s: severity must be positive", d.Name)
+		case !d.Priority.IsValid():
+			return nil, fmt.Errorf("level %s: invalid priority %q", d.Name, d.Priority)
+		case d.Color != "" && !isHexColor(d.Color):
+			return nil, fmt.Errorf("level %s: color must have the form #rrggbb", d.Name)
+		}
+		if _, dup := ls.defs[d.Name]; dup {
+			return nil, fmt.Errorf("level %s: defined twice", d.Name)
+		}
+		if other, dup := severities[d.Severity]; dup {
+			return nil, fmt.Errorf("level %s: severity %d is already used by %s", d.Name, d.Severity, other)
+		}
+		severities[d.Severity] = d.Name
+		ls.defs[d.Name] = d
+		ls.names = append(ls.names, d.Name)
+	}
+
+	for _, b := range BuiltinLevels {
+		if !ls.Has(b.Name) {
+			return nil, fmt.Errorf("built-in level %s is missing", b.Name)
+		}
+	}
+
+	sort.Slice(ls.names, func(i, j int) bool {
+		return ls.defs[ls.names[i]].Severity < ls.defs[ls.names[j]].Severity
+	})
+	return ls, nil
+}
+
+// DefaultLevels contains only the built-in levels
+var DefaultLevels = func() *Levels {
+	ls, err := NewLevels(BuiltinLevels)
+	if err != nil {
+		panic(err)
+	}
+	return ls
+}()
+
+// Has reports whether l is a known level
+func (ls *Levels) Has(l Level) bool {
+	_, ok := ls.defs[l]
+	return ok
+}
+
+// Get returns the definition of a level. Unknown levels rank 0 and are
+// labelled [UNKNOWN].
+func (ls *Levels) Get(l Level) LevelDef {
+	if d, ok := ls.defs[l]; ok {
+		return d
+	}
+	return LevelDef{Name: l, Prefix: "[UNKNOWN]", Priority: PriorityNormal}
+}
+
+// Severity returns the ranking of a level; unknown levels rank 0
+func (ls *Levels) Severity(l Level) int {
+	return ls.defs[l].Severity
+}
+
+// AtLeast reports whether l is at least as severe as threshold
+func (ls *Levels) AtLeast(l, threshold Level) bool {
+	return ls.Has(l) && ls.Severity(l) >= ls.Severity(threshold)
+}
+
+// Names returns the levels from least to most severe
+func (ls *Levels) Names() []Level {
+	return append([]Level(nil), ls.names...)
+}
+
+// InvalidError returns the error reported for a level that is not in the set
+func (ls *Levels) InvalidError() error {
+	names := make([]string, len(ls.names))
+	for i, l := range ls.names {
+		names[i] = string(l)
+	}
+	return fmt.Errorf("%w: must be one of %s", ErrInvalidLevel, strings.Join(names, ", "))
+}
+
+func isHexColor(s string) bool {
+	if len(s) != 7 || s[0] != '#' {
+		return false
+	}
+	for _, c := range s[1:] {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
@@ -1,6 +1,9 @@
 package notification
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // Level represents the severity level of a notification
 type Level string
@@ -12,37 +15,41 @@ const (
 	LevelCritical Level = "critical"
 )
 
-// ValidLevels contains all valid notification levels
-var ValidLevels = map[Level]bool{
-	LevelInfo:     true,
-	LevelWarning:  true,
-	LevelError:    true,
-	LevelCritical: true,
+// Priority determines which queue a notification is processed from
+type Priority string
+
+const (
+	PriorityLow    Priority = "low"
+	PriorityNormal Priority = "normal"
+	PriorityHigh   Priority = "high"
+)
+
+// priorityRanks orders priorities from lowest to highest
+var priorityRanks = map[Priority]int{
+	PriorityLow:    1,
+	PriorityNormal: 2,
+	PriorityHigh:   3,
+}
+
+// IsValid reports whether p is a known priority
+func (p Priority) IsValid() bool {
+	return priorityRanks[p] > 0
 }
 
-// LevelPrefix returns the prefix string for a given level
-func (l Level) Prefix() string {
-	switch l {
-	case LevelInfo:
-		return "[INFO]"
-	case LevelWarning:
-		return "[WARNING]"
-	case LevelError:
-		return "[ERROR]"
-	case LevelCritical:
-		return "[CRITICAL]"
-	default:
-		return "[UNKNOWN]"
-	}
+// Rank returns the ordering of the priority; higher is more urgent.
+// Unknown priorities rank 0.
+func (p Priority) Rank() int {
+	return priorityRanks[p]
 }
 
 // Channel represents a notification channel
 type Channel string
 
 const (
-	ChannelTelegram      Channel = "telegram"
-	ChannelEmail         Channel = "email"
-	ChannelWebhookPrefix         = "webhook:"
+	ChannelTelegram       Channel = "telegram"
+	ChannelEmail          Channel = "email"
+	ChannelWebhookPrefix          = "webhook:"
+	ChannelTelegramPrefix         = "telegram:" // named Telegram targets
 )
 
 // ValidChannels contains all valid notification channels
@@ -51,13 +58,55 @@ var ValidChannels = map[Channel]bool{
 	ChannelEmail:    true,
 }
 
+// IsValid reports whether c is a known channel or a webhook:<name> or
+// telegram:<name> channel
+func (c Channel) IsValid() bool {
+	return ValidChannels[c] || c.hasNamedPrefix(ChannelWebhookPrefix) || c.hasNamedPrefix(ChannelTelegramPrefix)
+}
+
+// hasNamedPrefix reports whether c is prefix followed by a non-empty name
+func (c Channel) hasNamedPrefix(prefix string) bool {
+	return strings.HasPrefix(string(c), prefix) && len(c) > len(prefix)
+}
+
 // Request represents an incoming notification request
 type Request struct {
 	Title    string    `json:"title"`
 	Message  string    `json:"message"`
 	Level    Level     `json:"level"`
-	Channels []Channel `json:"channel"`
+	Channels []Channel `json:"channel,omitempty"`
 	Source   string    `json:"source,omitempty"`
+	Tags     []string  `json:"tags,omitempty"`
+	Priority Priority  `json:"priority,omitempty"`
+	// Recipients overrides the default recipient per channel,
+	// e.g. {"telegram": "<chat id>"}
+	Recipients map[Channel]string `json:"recipients,omitempty"`
+	// Template names a configured template that provides the title and
+	// message, rendered with Vars
+	Template string         `json:"template,omitempty"`
+	Vars     map[string]any `json:"vars,omitempty"`
+	// Fingerprint identifies repeats of the same event; when empty it is
+	// computed from the configured fields
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// ChannelContent replaces the title and message for some channels.
+	// It is set when rendering a template with per-channel overrides.
+	ChannelContent map[Channel]Content `json:"-"`
+	// Schedules delays or silences delivery on some channels, e.g. during
+	// their quiet hours
+	Schedules map[Channel]Schedule `json:"-"`
+}
+
+// Schedule controls when and how a notification is delivered on one channel
+type Schedule struct {
+	DeliverAt time.Time // zero delivers immediately
+	Silent    bool      // deliver without sound, where the channel supports it
+	Digest    bool      // batch with other notifications into one summary
+}
+
+// Content is the title and message of a notification
+type Content struct {
+	Title   string
+	Message string
 }
 
 // Notification represents a notification to be sent
@@ -67,18 +116,59 @@ type Notification struct {
 	Message   string    `json:"message"`
 	Level     Level     `json:"level"`
 	Channel   Channel   `json:"channel"`
-	APIKey    string    `json:"api_key"`
+	APIKeyID  string    `json:"api_key_id"`
 	CreatedAt time.Time `json:"created_at"`
 	Source    string    `json:"source,omitempty"`
+	Tags      []string  `json:"tags,omitempty"`
+	Priority  Priority  `json:"priority,omitempty"`
+	Recipient string    `json:"recipient,omitempty"`
+	RequestID string    `json:"request_id,omitempty"` // X-Request-ID of the originating request
+	Silent    bool      `json:"silent,omitempty"`     // deliver without sound
+	// Digest lists the notifications a digest summarizes; it is empty for
+	// single notifications
+	Digest []*Notification `json:"digest,omitempty"`
+}
+
+// Response statuses
+const (
+	StatusQueued    = "queued"    // every channel was queued
+	StatusPartial   = "partial"   // some channels were queued, some failed
+	StatusFailed    = "failed"    // no channel was queued
+	StatusSkipped   = "skipped"   // every channel filtered the notification out
+	StatusDuplicate = "duplicate" // a repeat within the dedup window, suppressed
+	StatusMuted     = "muted"     // the source is muted for flooding, suppressed
+)
+
+// DeliveryStatus is the enqueue outcome for a single channel
+type DeliveryStatus string
+
+const (
+	DeliveryQueued  DeliveryStatus = "queued"
+	DeliveryFailed  DeliveryStatus = "failed"
+	DeliverySkipped DeliveryStatus = "skipped" // below the channel's minimum level
+)
+
+// Delivery reports the enqueue outcome for one concrete channel
+type Delivery struct {
+	Channel     Channel        `json:"channel"`
+	ID          string         `json:"id,omitempty"`
+	Status      DeliveryStatus `json:"status"`
+	Error       string         `json:"error,omitempty"`
+	ScheduledAt *time.Time     `json:"scheduled_at,omitempty"` // held until then, e.g. for quiet hours
+	Digest      bool           `json:"digest,omitempty"`       // batched into a digest
 }
 
 // Response represents the API response for a notification request
 type Response struct {
-	Status string `json:"status"`
-	ID     string `json:"id,omitempty"`
+	Status     string     `json:"status"`
+	Deliveries []Delivery `json:"deliveries"`
+	// Repeats counts the suppressed repeats so far, for duplicates
+	Repeats int `json:"repeats,omitempty"`
 }
 
 // ErrorResponse represents an error response
 type ErrorResponse struct {
-	Error string `json:"error"`
+	Error             string       `json:"error"`
+	Fields            []FieldError `json:"fields,omitempty"`
+	AvailableChannels []Channel    `json:"available_channels,omitempty"`
 }
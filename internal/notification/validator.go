@@ -2,52 +2,227 @@ package notification
 
 import (
 	"errors"
+	"fmt"
 	"strings"
+	"unicode/utf8"
+)
+
+// Field length limits, counted in characters
+const (
+	MaxTitleLength       = 256
+	MaxMessageLength     = 4000
+	MaxSourceLength      = 128
+	MaxFingerprintLength = 128
 )
 
 var (
-	ErrEmptyTitle     = errors.New("title is required")
-	ErrEmptyMessage   = errors.New("message is required")
-	ErrInvalidLevel   = errors.New("invalid level: must be one of info, warning, error, critical")
-	ErrEmptyChannels  = errors.New("at least one channel is required")
-	ErrInvalidChannel = errors.New("invalid channel: must be one of telegram, email, or webhook:<name>")
+	ErrEmptyTitle         = errors.New("title is required")
+	ErrTitleTooLong       = fmt.Errorf("title must be at most %d characters", MaxTitleLength)
+	ErrEmptyMessage       = errors.New("message is required")
+	ErrMessageTooLong     = fmt.Errorf("message must be at most %d characters", MaxMessageLength)
+	ErrSourceTooLong      = fmt.Errorf("source must be at most %d characters", MaxSourceLength)
+	ErrFingerprintTooLong = fmt.Errorf("fingerprint must be at most %d characters", MaxFingerprintLength)
+	ErrEmptyLevel         = errors.New("level is required")
+	ErrInvalidLevel       = errors.New("invalid level")
+	ErrEmptyChannels      = errors.New("at least one channel is required: none given and no routing rule matched")
+	ErrInvalidChannel     = errors.New("invalid channel")
+	ErrInvalidPriority    = errors.New("invalid priority: must be one of low, normal, high")
+	ErrInvalidRecipient   = errors.New("recipient overrides must name a targeted channel and a non-empty recipient")
+	ErrValidationFailed   = errors.New("validation failed")
+)
+
+// Validation error codes, stable for clients to switch on
+const (
+	CodeRequired       = "required"
+	CodeTooLong        = "too_long"
+	CodeInvalid        = "invalid"
+	CodeUnknownChannel = "unknown_channel"
 )
 
+// ChannelLookup reports which concrete channels are available for delivery.
+// It is implemented by channels.Registry.
+type ChannelLookup interface {
+	Has(name Channel) bool
+	Names() []Channel
+}
+
+// FieldError describes a single invalid request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	err     error
+}
+
+// ValidationError lists every invalid field of a request. When channels are
+// unknown, AvailableChannels lists what can be used instead.
+type ValidationError struct {
+	Fields            []FieldError
+	AvailableChannels []Channel
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap allows errors.Is checks against the per-field sentinel errors
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Fields)+1)
+	errs = append(errs, ErrValidationFailed)
+	for _, f := range e.Fields {
+		errs = append(errs, f.err)
+	}
+	return errs
+}
+
+func (e *ValidationError) add(field, code string, err error) {
+	e.Fields = append(e.Fields, FieldError{
+		Field:   field,
+		Code:    code,
+		Message: err.Error(),
+		err:     err,
+	})
+}
+
 // Validator validates notification requests
-type Validator struct{}
+type Validator struct {
+	groups   Groups
+	channels ChannelLookup
+	levels   *Levels
+}
 
-// NewValidator creates a new Validator
-func NewValidator() *Validator {
-	return &Validator{}
+// NewValidator creates a new Validator that accepts the given channel groups,
+// the channels currently registered in the lookup and the given levels
+func NewValidator(groups Groups, channels ChannelLookup, levels *Levels) *Validator {
+	return &Validator{groups: groups, channels: channels, levels: levels}
 }
 
-// Validate validates a notification request
+// Validate validates a notification request.
+// It returns a *ValidationError listing every invalid field, or nil.
 func (v *Validator) Validate(req *Request) error {
+	verr := &ValidationError{}
+
 	// Validate title
 	if strings.TrimSpace(req.Title) == "" {
-		return ErrEmptyTitle
+		verr.add("title", CodeRequired, ErrEmptyTitle)
+	} else if utf8.RuneCountInString(req.Title) > MaxTitleLength {
+		verr.add("title", CodeTooLong, ErrTitleTooLong)
 	}
 
 	// Validate message
 	if strings.TrimSpace(req.Message) == "" {
-		return ErrEmptyMessage
+		verr.add("message", CodeRequired, ErrEmptyMessage)
+	} else if utf8.RuneCountInString(req.Message) > MaxMessageLength {
+		verr.add("message", CodeTooLong, ErrMessageTooLong)
+	}
+
+	// Validate per-channel content rendered from a template
+	for _, c := range req.ChannelContent {
+		if utf8.RuneCountInString(c.Title) > MaxTitleLength {
+			verr.add("title", CodeTooLong, ErrTitleTooLong)
+			break
+		}
+		if utf8.RuneCountInString(c.Message) > MaxMessageLength {
+			verr.add("message", CodeTooLong, ErrMessageTooLong)
+			break
+		}
+	}
+
+	// Validate source
+	if utf8.RuneCountInString(req.Source) > MaxSourceLength {
+		verr.add("source", CodeTooLong, ErrSourceTooLong)
+	}
+
+	// Validate fingerprint
+	if utf8.RuneCountInString(req.Fingerprint) > MaxFingerprintLength {
+		verr.add("fingerprint", CodeTooLong, ErrFingerprintTooLong)
 	}
 
 	// Validate level
-	if !ValidLevels[req.Level] {
-		return ErrInvalidLevel
+	if req.Level == "" {
+		verr.add("level", CodeRequired, ErrEmptyLevel)
+	} else if !v.levels.Has(req.Level) {
+		verr.add("level", CodeInvalid, v.levels.InvalidError())
+	}
+
+	// Validate priority
+	if req.Priority != "" && !req.Priority.IsValid() {
+		verr.add("priority", CodeInvalid, ErrInvalidPriority)
+	}
+
+	// Validate recipient overrides
+	if len(req.Recipients) > 0 {
+		targets := v.groups.Expand(req.Channels)
+		for ch, recipient := range req.Recipients {
+			if strings.TrimSpace(recipient) == "" || !containsChannel(targets, ch) {
+				verr.add("recipients", CodeInvalid, ErrInvalidRecipient)
+				break
+			}
+		}
 	}
 
 	// Validate channels
 	if len(req.Channels) == 0 {
-		return ErrEmptyChannels
+		verr.add("channel", CodeRequired, ErrEmptyChannels)
+	} else if unknown := v.unknownChannels(req.Channels); len(unknown) > 0 {
+		verr.add("channel", CodeUnknownChannel, fmt.Errorf("%w: %s", ErrInvalidChannel, joinChannels(unknown)))
+		verr.AvailableChannels = v.available()
 	}
 
-	for _, ch := range req.Channels {
-		if !ValidChannels[ch] && !strings.HasPrefix(string(ch), ChannelWebhookPrefix) {
-			return ErrInvalidChannel
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// unknownChannels returns the requested channels and group members that are
+// neither registered nor configured groups
+func (v *Validator) unknownChannels(channels []Channel) []Channel {
+	var unknown []Channel
+	for _, ch := range channels {
+		if ch.IsGroup() {
+			if !v.groups.Has(ch) {
+				unknown = append(unknown, ch)
+			}
+			continue
+		}
+		if !v.channels.Has(ch) {
+			unknown = append(unknown, ch)
 		}
 	}
 
-	return nil
+	// Group members must be registered as well
+	for _, ch := range v.groups.Expand(channels) {
+		if !ch.IsGroup() && !v.channels.Has(ch) && !containsChannel(unknown, ch) {
+			unknown = append(unknown, ch)
+		}
+	}
+
+	return unknown
+}
+
+// available returns the registered channels followed by the configured groups
+func (v *Validator) available() []Channel {
+	return append(v.channels.Names(), v.groups.Names()...)
+}
+
+func containsChannel(channels []Channel, ch Channel) bool {
+	for _, c := range channels {
+		if c == ch {
+			return true
+		}
+	}
+	return false
+}
+
+func joinChannels(channels []Channel) string {
+	names := make([]string, len(channels))
+	for i, ch := range channels {
+		names[i] = string(ch)
+	}
+	return strings.Join(names, ", ")
 }
@@ -1,37 +1,52 @@
 package queue
 
 import (
-	"fmt"
+	"context"
 	"log/slog"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
+	"github.com/luytbq/personal-notification-service/internal/history"
 	"github.com/luytbq/personal-notification-service/internal/notification"
+	"github.com/luytbq/personal-notification-service/internal/stream"
+	"github.com/luytbq/personal-notification-service/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = tracing.Tracer("queue")
+
 // Client wraps the asynq client for enqueueing notifications
 type Client struct {
 	client     *asynq.Client
 	maxRetries int
 	logger     *slog.Logger
 	queueNames *QueueNames
+	history    history.Store // nil when history is disabled
+	events     *stream.Hub
+	groups     atomic.Pointer[notification.Groups]
 }
 
-// NewClient creates a new queue client
-func NewClient(redisAddr, redisPassword string, redisDB, maxRetries int, logger *slog.Logger, queueNames *QueueNames) *Client {
+// NewClient creates a new queue client. store may be nil.
+func NewClient(redisAddr, redisPassword string, redisDB, maxRetries int, logger *slog.Logger, queueNames *QueueNames, groups notification.Groups, store history.Store, events *stream.Hub) *Client {
 	client := asynq.NewClient(asynq.RedisClientOpt{
 		Addr:     redisAddr,
 		Password: redisPassword,
 		DB:       redisDB,
 	})
 
-	return &Client{
+	c := &Client{
 		client:     client,
 		maxRetries: maxRetries,
 		logger:     logger,
 		queueNames: queueNames,
+		history:    store,
+		events:     events,
 	}
+	c.SetGroups(groups)
+	return c
 }
 
 // Close closes the client connection
@@ -39,56 +54,178 @@ func (c *Client) Close() error {
 	return c.client.Close()
 }
 
-// Enqueue adds a notification to the queue
-// Returns the task ID and any error
-func (c *Client) Enqueue(req *notification.Request, apiKey string) ([]string, error) {
-	var taskIDs []string
+// SetGroups replaces the channel groups used to expand requests
+func (c *Client) SetGroups(groups notification.Groups) {
+	c.groups.Store(&groups)
+}
+
+// ExpandChannels resolves channel groups into de-duplicated concrete channels
+func (c *Client) ExpandChannels(channels []notification.Channel) []notification.Channel {
+	return c.groups.Load().Expand(channels)
+}
+
+// Enqueue adds a notification to the queue, one task per concrete channel.
+// Channel groups in the request are expanded and duplicates removed.
+// Enqueueing is not atomic across channels: a failure for one channel does not
+// stop the others, and the returned deliveries report the outcome of each.
+// Channels with an entry in req.ChannelContent get that title and message,
+// and those with an entry in req.Schedules are held or sent silently.
+// Only the API key ID is stored in the task payload, never the raw key.
+// Each task records a producer span whose context travels with the task, and
+// the request ID so deliveries can be correlated with the HTTP request.
+func (c *Client) Enqueue(ctx context.Context, req *notification.Request, apiKeyID, requestID string) []notification.Delivery {
+	var deliveries []notification.Delivery
 	now := time.Now()
 
-	for _, channel := range req.Channels {
+	for _, channel := range c.ExpandChannels(req.Channels) {
+		content := notification.Content{Title: req.Title, Message: req.Message}
+		if override, ok := req.ChannelContent[channel]; ok {
+			content = override
+		}
+
 		n := &notification.Notification{
 			ID:        uuid.New().String(),
-			Title:     req.Title,
-			Message:   req.Message,
+			Title:     content.Title,
+			Message:   content.Message,
 			Level:     req.Level,
 			Channel:   channel,
-			APIKey:    apiKey,
+			APIKeyID:  apiKeyID,
 			CreatedAt: now,
 			Source:    req.Source,
+			Tags:      req.Tags,
+			Priority:  req.Priority,
+			Recipient: req.Recipients[channel],
+			RequestID: requestID,
 		}
+		schedule := req.Schedules[channel]
+		n.Silent = schedule.Silent
 
-		task, err := NewNotificationTask(n, c.queueNames.TaskType)
+		// Record before enqueueing so the worker always finds the record
+		c.recordHistory(ctx, n)
+
+		queueName := c.queueNames.ForPriority(req.Priority)
+		spanCtx, span := tracer.Start(ctx, "enqueue "+string(channel),
+			trace.WithSpanKind(trace.SpanKindProducer),
+			trace.WithAttributes(
+				attribute.String("notification.id", n.ID),
+				attribute.String("notification.channel", string(channel)),
+				attribute.String("messaging.destination.name", queueName),
+			),
+		)
+
+		task, err := NewNotificationTask(spanCtx, n, c.queueNames.TaskType)
 		if err != nil {
+			tracing.Fail(span, err)
+			span.End()
+			c.markFailed(ctx, n, "failed to create task")
 			c.logger.Error("failed to create task",
+				slog.String("request_id", requestID),
 				slog.String("notification_id", n.ID),
 				slog.String("channel", string(channel)),
 				slog.String("error", err.Error()),
 			)
-			return nil, fmt.Errorf("failed to create task: %w", err)
+			deliveries = append(deliveries, notification.Delivery{
+				Channel: channel,
+				Status:  notification.DeliveryFailed,
+				Error:   "failed to create task",
+			})
+			continue
 		}
 
-		info, err := c.client.Enqueue(task,
+		opts := []asynq.Option{
 			asynq.MaxRetry(c.maxRetries),
-			asynq.Queue(c.queueNames.Notifications),
+			asynq.Queue(queueName),
 			asynq.TaskID(n.ID),
-		)
+		}
+		if !schedule.DeliverAt.IsZero() {
+			opts = append(opts, asynq.ProcessAt(schedule.DeliverAt))
+		}
+		if schedule.Digest {
+			opts = append(opts, asynq.Group(digestGroup(n)))
+		}
+
+		info, err := c.client.EnqueueContext(spanCtx, task, opts...)
 		if err != nil {
+			tracing.Fail(span, err)
+			span.End()
+			c.markFailed(ctx, n, "failed to enqueue task")
 			c.logger.Error("failed to enqueue task",
+				slog.String("request_id", requestID),
 				slog.String("notification_id", n.ID),
 				slog.String("channel", string(channel)),
 				slog.String("error", err.Error()),
 			)
-			return nil, fmt.Errorf("failed to enqueue task: %w", err)
+			deliveries = append(deliveries, notification.Delivery{
+				Channel: channel,
+				Status:  notification.DeliveryFailed,
+				Error:   "failed to enqueue task",
+			})
+			continue
 		}
 
+		span.End()
+		c.events.Publish(ctx, stream.NewEvent(stream.EventNotification, n, history.StatusQueued, "", 0))
+
 		c.logger.Info("notification queued",
+			slog.String("request_id", requestID),
 			slog.String("notification_id", n.ID),
 			slog.String("channel", string(channel)),
 			slog.String("queue", info.Queue),
+			slog.Bool("silent", n.Silent),
+			slog.Bool("digest", schedule.Digest),
 		)
 
-		taskIDs = append(taskIDs, n.ID)
+		d := notification.Delivery{
+			Channel: channel,
+			ID:      n.ID,
+			Status:  notification.DeliveryQueued,
+			Digest:  schedule.Digest,
+		}
+		if !schedule.DeliverAt.IsZero() {
+			d.ScheduledAt = &schedule.DeliverAt
+			c.logger.Info("notification held for quiet hours",
+				slog.String("notification_id", n.ID),
+				slog.Time("deliver_at", schedule.DeliverAt),
+			)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries
+}
+
+// EnqueueTask enqueues a task other than a notification, e.g. a scheduled
+// follow-up, on the normal priority queue unless opts choose another
+func (c *Client) EnqueueTask(ctx context.Context, task *asynq.Task, opts ...asynq.Option) error {
+	opts = append([]asynq.Option{asynq.Queue(c.queueNames.Notifications)}, opts...)
+	_, err := c.client.EnqueueContext(ctx, task, opts...)
+	return err
+}
+
+// recordHistory stores a new notification in the history. History is best
+// effort: a failure is logged and does not stop delivery.
+func (c *Client) recordHistory(ctx context.Context, n *notification.Notification) {
+	if c.history == nil {
+		return
 	}
+	if err := c.history.Record(ctx, n); err != nil {
+		c.logger.Warn("failed to record notification history",
+			slog.String("notification_id", n.ID),
+			slog.String("error", err.Error()),
+		)
+	}
+}
 
-	return taskIDs, nil
+// markFailed marks a notification that could not be enqueued as failed
+func (c *Client) markFailed(ctx context.Context, n *notification.Notification, reason string) {
+	c.events.Publish(ctx, stream.NewEvent(stream.EventNotification, n, history.StatusFailed, reason, 0))
+	if c.history == nil {
+		return
+	}
+	if err := c.history.SetStatus(ctx, n.ID, history.StatusFailed, reason); err != nil {
+		c.logger.Warn("failed to update notification history",
+			slog.String("notification_id", n.ID),
+			slog.String("error", err.Error()),
+		)
+	}
 }
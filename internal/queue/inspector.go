@@ -0,0 +1,153 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/luytbq/personal-notification-service/internal/notification"
+)
+
+// ErrTaskNotFound is returned when a dead-lettered task does not exist
+var ErrTaskNotFound = errors.New("task not found")
+
+// Inspector reads queue state for operators: queue depths and the dead
+// letter queue (asynq's archived tasks)
+type Inspector struct {
+	inspector  *asynq.Inspector
+	queueNames *QueueNames
+}
+
+// NewInspector creates a new queue inspector
+func NewInspector(redisAddr, redisPassword string, redisDB int, queueNames *QueueNames) *Inspector {
+	return &Inspector{
+		inspector: asynq.NewInspector(asynq.RedisClientOpt{
+			Addr:     redisAddr,
+			Password: redisPassword,
+			DB:       redisDB,
+		}),
+		queueNames: queueNames,
+	}
+}
+
+// Close closes the inspector connection
+func (i *Inspector) Close() error {
+	return i.inspector.Close()
+}
+
+// QueueStats is the depth of one queue by task state
+type QueueStats struct {
+	Queue      string `json:"queue"`
+	Pending    int    `json:"pending"`
+	Active     int    `json:"active"`
+	Scheduled  int    `json:"scheduled"`
+	Retry      int    `json:"retry"`
+	DeadLetter int    `json:"dead_letter"`
+	Processed  int    `json:"processed_today"`
+	Failed     int    `json:"failed_today"`
+	Paused     bool   `json:"paused"`
+}
+
+// DeadLetter is a notification task that exhausted its retries
+type DeadLetter struct {
+	ID           string               `json:"id"`
+	Queue        string               `json:"queue"`
+	Channel      notification.Channel `json:"channel"`
+	Title        string               `json:"title"`
+	Level        notification.Level   `json:"level"`
+	Source       string               `json:"source,omitempty"`
+	APIKeyID     string               `json:"api_key_id"`
+	RequestID    string               `json:"request_id,omitempty"`
+	Attempts     int                  `json:"attempts"`
+	LastError    string               `json:"last_error"`
+	LastFailedAt time.Time            `json:"last_failed_at"`
+}
+
+// queues lists the notification queues from highest to lowest priority
+func (i *Inspector) queues() []string {
+	return []string{i.queueNames.NotificationsHigh, i.queueNames.Notifications, i.queueNames.NotificationsLow}
+}
+
+// Queues returns the depth of every notification queue. Queues that never
+// held a task are reported as empty.
+func (i *Inspector) Queues() ([]QueueStats, error) {
+	existing, err := i.inspector.Queues()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list queues: %w", err)
+	}
+
+	stats := make([]QueueStats, 0, 3)
+	for _, name := range i.queues() {
+		if !slices.Contains(existing, name) {
+			stats = append(stats, QueueStats{Queue: name})
+			continue
+		}
+		info, err := i.inspector.GetQueueInfo(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect queue %s: %w", name, err)
+		}
+		stats = append(stats, QueueStats{
+			Queue:      name,
+			Pending:    info.Pending,
+			Active:     info.Active,
+			Scheduled:  info.Scheduled,
+			Retry:      info.Retry,
+			DeadLetter: info.Archived,
+			Processed:  info.Processed,
+			Failed:     info.Failed,
+			Paused:     info.Paused,
+		})
+	}
+	return stats, nil
+}
+
+// DeadLetters returns up to limit dead-lettered notifications per queue
+func (i *Inspector) DeadLetters(limit int) ([]DeadLetter, error) {
+	var letters []DeadLetter
+	for _, name := range i.queues() {
+		tasks, err := i.inspector.ListArchivedTasks(name, asynq.PageSize(limit))
+		if errors.Is(err, asynq.ErrQueueNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list dead letters in %s: %w", name, err)
+		}
+		for _, t := range tasks {
+			letter := DeadLetter{
+				ID:           t.ID,
+				Queue:        t.Queue,
+				Attempts:     t.Retried + 1,
+				LastError:    t.LastErr,
+				LastFailedAt: t.LastFailedAt,
+			}
+			var payload NotificationPayload
+			if err := json.Unmarshal(t.Payload, &payload); err == nil {
+				letter.Channel = payload.Channel
+				letter.Title = payload.Title
+				letter.Level = payload.Level
+				letter.Source = payload.Source
+				letter.APIKeyID = payload.APIKeyID
+				letter.RequestID = payload.RequestID
+			}
+			letters = append(letters, letter)
+		}
+	}
+	return letters, nil
+}
+
+// RetryDeadLetter moves a dead-lettered task back to its queue for another try
+func (i *Inspector) RetryDeadLetter(id string) error {
+	for _, name := range i.queues() {
+		err := i.inspector.RunTask(name, id)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, asynq.ErrTaskNotFound) && !errors.Is(err, asynq.ErrQueueNotFound) {
+			return fmt.Errorf("failed to retry task: %w", err)
+		}
+	}
+	return ErrTaskNotFound
+}
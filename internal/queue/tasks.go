@@ -1,56 +1,124 @@
 package queue
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"time"
 
 	"github.com/hibiken/asynq"
 	"github.com/luytbq/personal-notification-service/internal/notification"
+	"github.com/luytbq/personal-notification-service/internal/tracing"
 )
 
-// Task type suffix (will be prefixed with Redis key prefix)
+// Task type suffixes (will be prefixed with Redis key prefix)
 const (
-	TaskTypeSuffix = "notification:send"
+	TaskTypeSuffix       = "notification:send"
+	DigestTaskTypeSuffix = "notification:digest"
 )
 
 // QueueNames holds the prefixed queue and task names
 type QueueNames struct {
-	Notifications string
-	TaskType      string
+	Notifications     string // normal priority
+	NotificationsHigh string
+	NotificationsLow  string
+	TaskType          string
+	DigestTaskType    string // notifications batched by a digest channel
 }
 
 // NewQueueNames creates queue names with the given prefix
 func NewQueueNames(prefix string) *QueueNames {
 	return &QueueNames{
-		Notifications: fmt.Sprintf("%s:notifications", prefix),
-		TaskType:      fmt.Sprintf("%s:%s", prefix, TaskTypeSuffix),
+		Notifications:     fmt.Sprintf("%s:notifications", prefix),
+		NotificationsHigh: fmt.Sprintf("%s:notifications:high", prefix),
+		NotificationsLow:  fmt.Sprintf("%s:notifications:low", prefix),
+		TaskType:          fmt.Sprintf("%s:%s", prefix, TaskTypeSuffix),
+		DigestTaskType:    fmt.Sprintf("%s:%s", prefix, DigestTaskTypeSuffix),
+	}
+}
+
+// ForPriority returns the queue name for a notification priority
+func (q *QueueNames) ForPriority(p notification.Priority) string {
+	switch p {
+	case notification.PriorityHigh:
+		return q.NotificationsHigh
+	case notification.PriorityLow:
+		return q.NotificationsLow
+	default:
+		return q.Notifications
 	}
 }
 
 // NotificationPayload represents the payload for a notification task
 type NotificationPayload struct {
-	ID        string               `json:"id"`
-	Title     string               `json:"title"`
-	Message   string               `json:"message"`
-	Level     notification.Level   `json:"level"`
-	Channel   notification.Channel `json:"channel"`
-	APIKey    string               `json:"api_key"`
-	CreatedAt time.Time            `json:"created_at"`
-	Source    string               `json:"source,omitempty"`
+	ID        string                `json:"id"`
+	Title     string                `json:"title"`
+	Message   string                `json:"message"`
+	Level     notification.Level    `json:"level"`
+	Channel   notification.Channel  `json:"channel"`
+	APIKeyID  string                `json:"api_key_id"`
+	CreatedAt time.Time             `json:"created_at"`
+	Source    string                `json:"source,omitempty"`
+	Tags      []string              `json:"tags,omitempty"`
+	Priority  notification.Priority `json:"priority,omitempty"`
+	Recipient string                `json:"recipient,omitempty"`
+	RequestID string                `json:"request_id,omitempty"`
+	Silent    bool                  `json:"silent,omitempty"`
+	// TraceContext carries the enqueuing span (W3C traceparent/tracestate)
+	// so delivery continues the request's trace
+	TraceContext map[string]string `json:"trace_context,omitempty"`
+}
+
+// Notification converts the payload back to a notification
+func (p *NotificationPayload) Notification() *notification.Notification {
+	return &notification.Notification{
+		ID:        p.ID,
+		Title:     p.Title,
+		Message:   p.Message,
+		Level:     p.Level,
+		Channel:   p.Channel,
+		APIKeyID:  p.APIKeyID,
+		CreatedAt: p.CreatedAt,
+		Source:    p.Source,
+		Tags:      p.Tags,
+		Priority:  p.Priority,
+		Recipient: p.Recipient,
+		RequestID: p.RequestID,
+		Silent:    p.Silent,
+	}
 }
 
-// NewNotificationTask creates a new notification task
-func NewNotificationTask(n *notification.Notification, taskType string) (*asynq.Task, error) {
+// DigestPayload is the payload of a digest task: the notifications of one
+// channel and recipient that were batched together
+type DigestPayload struct {
+	Items []NotificationPayload `json:"items"`
+}
+
+// digestGroup returns the asynq group of a notification sent by a digest
+// channel. Each channel and recipient gets its own digest.
+func digestGroup(n *notification.Notification) string {
+	return string(n.Channel) + "|" + n.Recipient
+}
+
+// NewNotificationTask creates a new notification task carrying the trace
+// context of ctx
+func NewNotificationTask(ctx context.Context, n *notification.Notification, taskType string) (*asynq.Task, error) {
 	payload := NotificationPayload{
 		ID:        n.ID,
 		Title:     n.Title,
 		Message:   n.Message,
 		Level:     n.Level,
 		Channel:   n.Channel,
-		APIKey:    n.APIKey,
+		APIKeyID:  n.APIKeyID,
 		CreatedAt: n.CreatedAt,
 		Source:    n.Source,
+		Tags:      n.Tags,
+		Priority:  n.Priority,
+		Recipient: n.Recipient,
+		RequestID: n.RequestID,
+		Silent:    n.Silent,
+
+		TraceContext: tracing.Inject(ctx),
 	}
 
 	data, err := json.Marshal(payload)
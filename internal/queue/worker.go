@@ -3,13 +3,22 @@ package queue
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"sync/atomic"
 	"time"
 
 	"github.com/hibiken/asynq"
 	"github.com/luytbq/personal-notification-service/internal/channels"
+	"github.com/luytbq/personal-notification-service/internal/history"
 	"github.com/luytbq/personal-notification-service/internal/notification"
+	"github.com/luytbq/personal-notification-service/internal/silence"
+	"github.com/luytbq/personal-notification-service/internal/stream"
+	"github.com/luytbq/personal-notification-service/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Worker handles processing of notification tasks
@@ -18,11 +27,31 @@ type Worker struct {
 	mux        *asynq.ServeMux
 	registry   *channels.Registry
 	logger     *slog.Logger
+	chLogger   *slog.Logger
 	queueNames *QueueNames
+	history    history.Store // nil when history is disabled
+	events     *stream.Hub
+	formatters atomic.Pointer[notification.Formatters]
+	maxRetries int // for digests
+	silences   *silence.Store
 }
 
-// NewWorker creates a new worker
-func NewWorker(redisAddr, redisPassword string, redisDB, concurrency int, registry *channels.Registry, logger *slog.Logger, queueNames *QueueNames) *Worker {
+// DigestOptions controls when batched notifications are sent as a digest
+type DigestOptions struct {
+	GracePeriod time.Duration // send once no notification arrived for this long
+	MaxDelay    time.Duration // send at the latest this long after the first
+	MaxSize     int           // send once this many are batched
+	MaxRetries  int           // retries of a digest that fails to send
+}
+
+// NewWorker creates a new worker. chLogger is handed to channels for
+// delivery details, so they can be logged at their own level. store may be nil.
+// Every attempt is published to events for live streaming. formatters render
+// notifications for each channel. digest controls the batching of
+// notifications for digest channels. Notifications matching one of silences
+// are recorded as silenced instead of sent; silences may be nil.
+func NewWorker(redisAddr, redisPassword string, redisDB, concurrency int, registry *channels.Registry, logger, chLogger *slog.Logger, queueNames *QueueNames, store history.Store, events *stream.Hub, formatters *notification.Formatters, digest DigestOptions, silences *silence.Store) *Worker {
+	var w *Worker
 	server := asynq.NewServer(
 		asynq.RedisClientOpt{
 			Addr:     redisAddr,
@@ -32,8 +61,19 @@ func NewWorker(redisAddr, redisPassword string, redisDB, concurrency int, regist
 		asynq.Config{
 			Concurrency: concurrency,
 			Queues: map[string]int{
-				queueNames.Notifications: 10, // Priority weight
+				// Priority weights
+				queueNames.NotificationsHigh: 6,
+				queueNames.Notifications:     3,
+				queueNames.NotificationsLow:  1,
 			},
+			// Notifications for digest channels are batched per channel and
+			// recipient, then sent as one digest
+			GroupGracePeriod: digest.GracePeriod,
+			GroupMaxDelay:    digest.MaxDelay,
+			GroupMaxSize:     digest.MaxSize,
+			GroupAggregator: asynq.GroupAggregatorFunc(func(group string, tasks []*asynq.Task) *asynq.Task {
+				return w.aggregate(group, tasks)
+			}),
 			RetryDelayFunc: func(n int, e error, t *asynq.Task) time.Duration {
 				// Exponential backoff: 10s, 20s, 40s, 80s, 160s
 				return time.Duration(10<<uint(n-1)) * time.Second
@@ -42,19 +82,34 @@ func NewWorker(redisAddr, redisPassword string, redisDB, concurrency int, regist
 				retried, _ := asynq.GetRetryCount(ctx)
 				maxRetry, _ := asynq.GetMaxRetry(ctx)
 
+				// Other task types, e.g. follow-ups, log their own details
+				if task.Type() != queueNames.TaskType {
+					logger.Warn("task failed",
+						slog.String("task_type", task.Type()),
+						slog.String("error", err.Error()),
+						slog.Int("attempt", retried+1),
+					)
+					return
+				}
+
 				var payload NotificationPayload
 				if jsonErr := json.Unmarshal(task.Payload(), &payload); jsonErr == nil {
-					if retried >= maxRetry {
+					if retried >= maxRetry || errors.Is(err, asynq.SkipRetry) {
 						// This is the final failure - log for dead letter tracking
 						logger.Error("notification moved to dead letter queue",
+							slog.String("request_id", payload.RequestID),
 							slog.String("notification_id", payload.ID),
 							slog.String("channel", string(payload.Channel)),
 							slog.String("error", err.Error()),
 							slog.Int("attempts", retried+1),
-							slog.Any("payload", payload),
+							slog.String("level", string(payload.Level)),
+							slog.String("title", payload.Title),
+							slog.String("source", payload.Source),
+							slog.String("api_key_id", payload.APIKeyID),
 						)
 					} else {
 						logger.Warn("notification task failed, will retry",
+							slog.String("request_id", payload.RequestID),
 							slog.String("notification_id", payload.ID),
 							slog.String("channel", string(payload.Channel)),
 							slog.String("error", err.Error()),
@@ -69,20 +124,38 @@ func NewWorker(redisAddr, redisPassword string, redisDB, concurrency int, regist
 
 	mux := asynq.NewServeMux()
 
-	w := &Worker{
+	w = &Worker{
 		server:     server,
 		mux:        mux,
 		registry:   registry,
 		logger:     logger,
+		chLogger:   chLogger,
 		queueNames: queueNames,
+		history:    store,
+		events:     events,
+		maxRetries: digest.MaxRetries,
+		silences:   silences,
 	}
+	w.SetFormatters(formatters)
 
 	// Register handlers
 	mux.HandleFunc(queueNames.TaskType, w.handleNotification)
+	mux.HandleFunc(queueNames.DigestTaskType, w.handleDigest)
 
 	return w
 }
 
+// Handle registers a handler for another task type, e.g. scheduled follow-ups.
+// It must be called before Start.
+func (w *Worker) Handle(taskType string, handler asynq.Handler) {
+	w.mux.Handle(taskType, handler)
+}
+
+// SetFormatters replaces the formatters used for new deliveries
+func (w *Worker) SetFormatters(formatters *notification.Formatters) {
+	w.formatters.Store(formatters)
+}
+
 // Start starts the worker
 func (w *Worker) Start() error {
 	return w.server.Start(w.mux)
@@ -105,31 +178,63 @@ func (w *Worker) handleNotification(ctx context.Context, task *asynq.Task) error
 		return fmt.Errorf("failed to parse payload: %w", err)
 	}
 
+	// Continue the trace of the request that enqueued the task
+	retried, _ := asynq.GetRetryCount(ctx)
+	ctx, span := tracer.Start(tracing.Extract(ctx, payload.TraceContext), "deliver "+string(payload.Channel),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("notification.id", payload.ID),
+			attribute.String("notification.channel", string(payload.Channel)),
+			attribute.String("notification.level", string(payload.Level)),
+			attribute.Int("attempt", retried+1),
+			attribute.String("request.id", payload.RequestID),
+		),
+	)
+	defer span.End()
+
+	n := payload.Notification()
+
 	// Get the channel
 	ch, ok := w.registry.Get(payload.Channel)
 	if !ok {
+		span.SetStatus(codes.Error, "unknown channel")
+		w.recordAttempt(ctx, n, start, errors.New("unknown channel"), true)
 		w.logger.Error("unknown channel",
+			slog.String("request_id", payload.RequestID),
 			slog.String("notification_id", payload.ID),
 			slog.String("channel", string(payload.Channel)),
 		)
-		return fmt.Errorf("unknown channel: %s", payload.Channel)
+		// Retrying cannot make an unregistered channel appear
+		return fmt.Errorf("unknown channel: %s: %w", payload.Channel, asynq.SkipRetry)
 	}
 
-	// Convert payload to notification
-	n := &notification.Notification{
-		ID:        payload.ID,
-		Title:     payload.Title,
-		Message:   payload.Message,
-		Level:     payload.Level,
-		Channel:   payload.Channel,
-		APIKey:    payload.APIKey,
-		CreatedAt: payload.CreatedAt,
-		Source:    payload.Source,
+	// Silences are checked at delivery, so that they also catch notifications
+	// that were held or batched before the silence was created
+	if sil := w.silenced(ctx, n); sil != nil {
+		w.recordSilenced(ctx, n, sil)
+		return nil
 	}
 
+	w.logger.Debug("delivering notification",
+		slog.String("request_id", n.RequestID),
+		slog.String("notification_id", n.ID),
+		slog.String("channel", string(n.Channel)),
+		slog.String("level", string(n.Level)),
+		slog.String("priority", string(n.Priority)),
+	)
+
 	// Send the notification
-	if err := ch.Send(ctx, n); err != nil {
+	chLogger := w.chLogger.With(slog.String("request_id", n.RequestID))
+	sendCtx := channels.WithLogger(ctx, chLogger)
+	sendCtx = channels.WithFormatter(sendCtx, w.formatters.Load().For(n.Channel))
+	err = send(sendCtx, ch, n)
+	w.registry.RecordResult(n.Channel, err)
+	if err != nil {
+		tracing.Fail(span, err)
+		maxRetry, _ := asynq.GetMaxRetry(ctx)
+		w.recordAttempt(ctx, n, start, err, retried >= maxRetry || errors.Is(err, asynq.SkipRetry))
 		w.logger.Error("notification failed",
+			slog.String("request_id", n.RequestID),
 			slog.String("notification_id", n.ID),
 			slog.String("channel", string(n.Channel)),
 			slog.String("status", "failed"),
@@ -139,7 +244,10 @@ func (w *Worker) handleNotification(ctx context.Context, task *asynq.Task) error
 		return err
 	}
 
+	w.recordAttempt(ctx, n, start, nil, true)
+
 	w.logger.Info("notification sent",
+		slog.String("request_id", n.RequestID),
 		slog.String("notification_id", n.ID),
 		slog.String("channel", string(n.Channel)),
 		slog.String("status", "sent"),
@@ -148,3 +256,218 @@ func (w *Worker) handleNotification(ctx context.Context, task *asynq.Task) error
 
 	return nil
 }
+
+// aggregate merges the notifications batched in a digest group into one
+// digest task
+func (w *Worker) aggregate(group string, tasks []*asynq.Task) *asynq.Task {
+	payload := DigestPayload{Items: make([]NotificationPayload, 0, len(tasks))}
+	for _, t := range tasks {
+		p, err := ParseNotificationPayload(t)
+		if err != nil {
+			w.logger.Error("dropping unreadable notification from digest",
+				slog.String("group", group),
+				slog.String("error", err.Error()),
+			)
+			continue
+		}
+		payload.Items = append(payload.Items, *p)
+	}
+
+	// Cannot fail: the payload only holds plain values
+	data, _ := json.Marshal(payload)
+	return asynq.NewTask(w.queueNames.DigestTaskType, data, asynq.MaxRetry(w.maxRetries))
+}
+
+// handleDigest sends a batch of notifications for one channel and recipient
+// as a single digest. Every batched notification shares the outcome.
+func (w *Worker) handleDigest(ctx context.Context, task *asynq.Task) error {
+	start := time.Now()
+
+	var payload DigestPayload
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		w.logger.Error("failed to parse digest payload",
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to parse payload: %w", err)
+	}
+	if len(payload.Items) == 0 {
+		return nil
+	}
+
+	items := make([]*notification.Notification, 0, len(payload.Items))
+	for i := range payload.Items {
+		item := payload.Items[i].Notification()
+		if sil := w.silenced(ctx, item); sil != nil {
+			w.recordSilenced(ctx, item, sil)
+			continue
+		}
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	formatter := w.formatters.Load().For(items[0].Channel)
+	n := newDigest(ctx, items, formatter)
+
+	retried, _ := asynq.GetRetryCount(ctx)
+	ctx, span := tracer.Start(ctx, "deliver digest "+string(n.Channel),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("notification.id", n.ID),
+			attribute.String("notification.channel", string(n.Channel)),
+			attribute.Int("digest.size", len(items)),
+			attribute.Int("attempt", retried+1),
+		),
+	)
+	defer span.End()
+
+	ch, ok := w.registry.Get(n.Channel)
+	if !ok {
+		span.SetStatus(codes.Error, "unknown channel")
+		for _, item := range items {
+			w.recordAttempt(ctx, item, start, errors.New("unknown channel"), true)
+		}
+		w.logger.Error("unknown channel",
+			slog.String("digest_id", n.ID),
+			slog.String("channel", string(n.Channel)),
+		)
+		return fmt.Errorf("unknown channel: %s: %w", n.Channel, asynq.SkipRetry)
+	}
+
+	sendCtx := channels.WithLogger(ctx, w.chLogger.With(slog.String("digest_id", n.ID)))
+	sendCtx = channels.WithFormatter(sendCtx, formatter)
+	err := send(sendCtx, ch, n)
+	w.registry.RecordResult(n.Channel, err)
+
+	final := true
+	if err != nil {
+		tracing.Fail(span, err)
+		maxRetry, _ := asynq.GetMaxRetry(ctx)
+		final = retried >= maxRetry || errors.Is(err, asynq.SkipRetry)
+	}
+	for _, item := range items {
+		w.recordAttempt(ctx, item, start, err, final)
+	}
+
+	if err != nil {
+		w.logger.Error("digest failed",
+			slog.String("digest_id", n.ID),
+			slog.String("channel", string(n.Channel)),
+			slog.Int("notifications", len(items)),
+			slog.String("status", "failed"),
+			slog.String("error", err.Error()),
+			slog.Duration("latency", time.Since(start)),
+		)
+		return err
+	}
+
+	w.logger.Info("digest sent",
+		slog.String("digest_id", n.ID),
+		slog.String("channel", string(n.Channel)),
+		slog.Int("notifications", len(items)),
+		slog.String("status", "sent"),
+		slog.Duration("latency", time.Since(start)),
+	)
+	return nil
+}
+
+// newDigest builds the notification that summarizes items. It takes the
+// level of the most severe item and is silent only if every item is.
+func newDigest(ctx context.Context, items []*notification.Notification, formatter notification.Formatter) *notification.Notification {
+	id, _ := asynq.GetTaskID(ctx)
+	n := &notification.Notification{
+		ID:        id,
+		Title:     fmt.Sprintf("Digest of %d %s", len(items), notification.Plural(len(items), "notification", "notifications")),
+		Level:     items[0].Level,
+		Channel:   items[0].Channel,
+		CreatedAt: time.Now(),
+		Recipient: items[0].Recipient,
+		Silent:    true,
+		Digest:    items,
+	}
+	for _, item := range items {
+		if formatter.Level(item.Level).Severity > formatter.Level(n.Level).Severity {
+			n.Level = item.Level
+		}
+		n.Silent = n.Silent && item.Silent
+	}
+	return n
+}
+
+// silenced returns the active silence matching n, or nil. Notifications are
+// delivered when the silences cannot be read.
+func (w *Worker) silenced(ctx context.Context, n *notification.Notification) *silence.Silence {
+	if w.silences == nil {
+		return nil
+	}
+	sil, err := w.silences.Match(ctx, n)
+	if err != nil {
+		w.logger.Warn("failed to check silences",
+			slog.String("notification_id", n.ID),
+			slog.String("error", err.Error()),
+		)
+		return nil
+	}
+	return sil
+}
+
+// recordSilenced stores that n was silenced instead of sent
+func (w *Worker) recordSilenced(ctx context.Context, n *notification.Notification, sil *silence.Silence) {
+	reason := fmt.Sprintf("silenced by %s: %s", sil.ID, sil.Comment)
+	w.logger.Info("notification silenced",
+		slog.String("request_id", n.RequestID),
+		slog.String("notification_id", n.ID),
+		slog.String("channel", string(n.Channel)),
+		slog.String("silence_id", sil.ID),
+		slog.String("status", string(history.StatusSilenced)),
+	)
+
+	w.events.Publish(ctx, stream.NewEvent(stream.EventStatus, n, history.StatusSilenced, reason, 0))
+	if w.history == nil {
+		return
+	}
+	if err := w.history.SetStatus(ctx, n.ID, history.StatusSilenced, reason); err != nil {
+		w.logger.Warn("failed to update notification history",
+			slog.String("notification_id", n.ID),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// send delivers n through ch. Request URLs are cut from the error, so that
+// secrets such as the bot token never reach the history, events, channel
+// health or logs, whichever channel failed.
+func send(ctx context.Context, ch channels.Channel, n *notification.Notification) error {
+	return channels.RedactURL(ch.Send(ctx, n))
+}
+
+// recordAttempt stores a delivery attempt in the history and publishes the
+// resulting status. final marks the last attempt: a failure is then permanent
+// rather than retried.
+func (w *Worker) recordAttempt(ctx context.Context, n *notification.Notification, start time.Time, err error, final bool) {
+	retried, _ := asynq.GetRetryCount(ctx)
+	a := history.Attempt{
+		Attempt:   retried + 1,
+		LatencyMS: time.Since(start).Milliseconds(),
+		StartedAt: start,
+	}
+	status := history.StatusSent
+	if err != nil {
+		a.Error = err.Error()
+		status = history.StatusRetrying
+		if final {
+			status = history.StatusFailed
+		}
+	}
+
+	w.events.Publish(ctx, stream.NewEvent(stream.EventStatus, n, status, a.Error, a.Attempt))
+	if w.history == nil {
+		return
+	}
+	if err := w.history.RecordAttempt(ctx, n.ID, a, status); err != nil {
+		w.logger.Warn("failed to record delivery attempt",
+			slog.String("notification_id", n.ID),
+			slog.String("error", err.Error()),
+		)
+	}
+}
@@ -0,0 +1,130 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/hibiken/asynq"
+	"github.com/luytbq/personal-notification-service/internal/channels"
+	"github.com/luytbq/personal-notification-service/internal/history"
+	"github.com/luytbq/personal-notification-service/internal/notification"
+	"github.com/luytbq/personal-notification-service/internal/stream"
+)
+
+// testSecret stands for a secret in a channel's URL, e.g. the bot token
+const testSecret = "123456:very-secret-token"
+
+// leakyChannel fails every delivery with the error of an HTTP client, which
+// quotes the full request URL
+type leakyChannel struct{}
+
+func (leakyChannel) Name() notification.Channel {
+	return notification.ChannelTelegram
+}
+
+func (leakyChannel) Send(context.Context, *notification.Notification) error {
+	err := &url.Error{
+		Op:  "Post",
+		URL: "https://api.telegram.org/bot" + testSecret + "/sendMessage",
+		Err: errors.New("connection refused"),
+	}
+	return fmt.Errorf("failed to send telegram message: %w: %w", err, asynq.SkipRetry)
+}
+
+// attemptStore records the attempts the worker stores
+type attemptStore struct {
+	history.Store // only RecordAttempt is used
+	mu            sync.Mutex
+	attempts      []history.Attempt
+	done          chan struct{}
+}
+
+func (s *attemptStore) RecordAttempt(_ context.Context, _ string, a history.Attempt, _ history.Status) error {
+	s.mu.Lock()
+	s.attempts = append(s.attempts, a)
+	s.mu.Unlock()
+	s.done <- struct{}{}
+	return nil
+}
+
+// TestWorkerRedactsChannelErrors checks that the URL of a failed request,
+// and the secret in it, stays out of what the worker records and publishes
+func TestWorkerRedactsChannelErrors(t *testing.T) {
+	redis := miniredis.RunT(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	names := NewQueueNames("pns")
+	hub := stream.NewHub(redis.Addr(), "", 0, "pns", logger)
+	registry := channels.NewRegistry()
+	registry.Register(leakyChannel{})
+	store := &attemptStore{done: make(chan struct{}, 1)}
+
+	client := NewClient(redis.Addr(), "", 0, 0, logger, names, nil, nil, hub)
+	defer client.Close()
+	worker := NewWorker(redis.Addr(), "", 0, 1, registry, logger, logger, names, store, hub,
+		notification.NewFormatters(notification.FormatOptions{}, nil),
+		DigestOptions{GracePeriod: time.Minute, MaxDelay: time.Hour, MaxSize: 10},
+		nil,
+	)
+	if err := worker.Start(); err != nil {
+		t.Fatalf("worker.Start: %v", err)
+	}
+	defer worker.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.Run(ctx)
+	sub := hub.Subscribe(stream.Filter{})
+	defer sub.Close()
+	for len(redis.PubSubChannels("")) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	req := &notification.Request{
+		Title:    "Backup failed",
+		Message:  "disk full",
+		Level:    notification.LevelError,
+		Channels: []notification.Channel{notification.ChannelTelegram},
+	}
+	client.Enqueue(ctx, req, "test", "req-1")
+
+	select {
+	case <-store.done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("no delivery attempt was recorded")
+	}
+
+	store.mu.Lock()
+	got := store.attempts[0].Error
+	store.mu.Unlock()
+	if got == "" || strings.Contains(got, testSecret) {
+		t.Errorf("history error = %q, want it without the secret", got)
+	}
+	if !strings.Contains(got, "https://api.telegram.org") {
+		t.Errorf("history error = %q, want it to name the host", got)
+	}
+
+	// Status events reach every stream subscriber
+	for {
+		select {
+		case e := <-sub.Events():
+			if e.Type != stream.EventStatus {
+				continue
+			}
+			if e.Error == "" || strings.Contains(e.Error, testSecret) {
+				t.Errorf("event error = %q, want it without the secret", e.Error)
+			}
+			return
+		case <-time.After(10 * time.Second):
+			t.Fatal("no status event was published")
+		}
+	}
+}
@@ -1,7 +1,7 @@
 package ratelimit
 
 import (
-	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -10,7 +10,7 @@ import (
 
 // Limiter implements a token bucket rate limiter per API key and channel
 type Limiter struct {
-	limiters map[string]*rate.Limiter
+	limiters map[bucketKey]*rate.Limiter
 	mu       sync.RWMutex
 	rate     rate.Limit // requests per second
 	burst    int        // bucket size
@@ -20,15 +20,34 @@ type Limiter struct {
 // requestsPerMinute: maximum requests allowed per minute per key+channel combination
 func NewLimiter(requestsPerMinute int) *Limiter {
 	return &Limiter{
-		limiters: make(map[string]*rate.Limiter),
+		limiters: make(map[bucketKey]*rate.Limiter),
 		rate:     rate.Limit(float64(requestsPerMinute) / 60.0), // convert to per-second
 		burst:    requestsPerMinute,                             // allow burst up to the full minute limit
 	}
 }
 
+// SetRate changes the limit for all existing and future buckets
+func (l *Limiter) SetRate(requestsPerMinute int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.rate = rate.Limit(float64(requestsPerMinute) / 60.0)
+	l.burst = requestsPerMinute
+	for _, limiter := range l.limiters {
+		limiter.SetLimit(l.rate)
+		limiter.SetBurst(l.burst)
+	}
+}
+
+// bucketKey identifies the bucket of an API key and channel combination
+type bucketKey struct {
+	apiKey  string
+	channel string
+}
+
 // key generates a unique key for the API key and channel combination
-func (l *Limiter) key(apiKey, channel string) string {
-	return fmt.Sprintf("%s:%s", apiKey, channel)
+func (l *Limiter) key(apiKey, channel string) bucketKey {
+	return bucketKey{apiKey: apiKey, channel: channel}
 }
 
 // getLimiter returns the rate limiter for a given API key and channel, creating one if needed
@@ -68,3 +87,36 @@ func (l *Limiter) Allow(apiKey, channel string) bool {
 func (l *Limiter) AllowN(apiKey, channel string, n int) bool {
 	return l.getLimiter(apiKey, channel).AllowN(time.Now(), n)
 }
+
+// Usage is the state of one API key and channel bucket
+type Usage struct {
+	APIKeyID  string  `json:"api_key_id"`
+	Channel   string  `json:"channel"`
+	Available float64 `json:"available"` // requests that may be sent right now
+	Burst     int     `json:"burst"`     // bucket size
+}
+
+// Usage returns the state of every bucket, sorted by API key and channel.
+// Buckets are created on first use, so unused combinations are absent.
+func (l *Limiter) Usage() []Usage {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	now := time.Now()
+	usage := make([]Usage, 0, len(l.limiters))
+	for key, limiter := range l.limiters {
+		usage = append(usage, Usage{
+			APIKeyID:  key.apiKey,
+			Channel:   key.channel,
+			Available: max(limiter.TokensAt(now), 0),
+			Burst:     limiter.Burst(),
+		})
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].APIKeyID != usage[j].APIKeyID {
+			return usage[i].APIKeyID < usage[j].APIKeyID
+		}
+		return usage[i].Channel < usage[j].Channel
+	})
+	return usage
+}
@@ -0,0 +1,219 @@
+package routing
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+
+	"github.com/luytbq/personal-notification-service/internal/config"
+	"github.com/luytbq/personal-notification-service/internal/notification"
+)
+
+// rule is a compiled routing rule
+type rule struct {
+	name        string
+	levels      map[notification.Level]bool
+	minLevel    notification.Level
+	source      string
+	sourceRegex *regexp.Regexp
+	titleRegex  *regexp.Regexp
+	tags        []string
+	apiKeys     map[string]bool
+	channels    []notification.Channel
+	cont        bool
+	digest      bool
+}
+
+// Engine resolves channels for requests that do not list any
+type Engine struct {
+	rules    []rule
+	fallback []notification.Channel
+	levels   *notification.Levels
+}
+
+// NewEngine compiles the routing configuration into an Engine.
+// Rules may target any concrete channel or one of the given groups, and
+// match any of the given levels.
+func NewEngine(cfg config.RoutingConfig, groups notification.Groups, levels *notification.Levels) (*Engine, error) {
+	valid := func(ch notification.Channel) bool {
+		return ch.IsValid() || groups.Has(ch)
+	}
+
+	e := &Engine{
+		fallback: toChannels(cfg.Fallback),
+		levels:   levels,
+	}
+
+	for _, ch := range e.fallback {
+		if !valid(ch) {
+			return nil, fmt.Errorf("routing fallback: invalid channel %q", ch)
+		}
+	}
+
+	for i, rc := range cfg.Rules {
+		name := rc.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+
+		if len(rc.Channels) == 0 {
+			return nil, fmt.Errorf("routing rule %s: at least one channel is required", name)
+		}
+
+		for _, ch := range rc.Channels {
+			if !valid(notification.Channel(ch)) {
+				return nil, fmt.Errorf("routing rule %s: invalid channel %q", name, ch)
+			}
+		}
+
+		r := rule{
+			name:     name,
+			minLevel: notification.Level(rc.Match.MinLevel),
+			source:   rc.Match.Source,
+			tags:     rc.Match.Tags,
+			channels: toChannels(rc.Channels),
+			cont:     rc.Continue,
+			digest:   rc.Digest,
+		}
+
+		if len(rc.Match.Levels) > 0 {
+			r.levels = make(map[notification.Level]bool, len(rc.Match.Levels))
+			for _, l := range rc.Match.Levels {
+				level := notification.Level(l)
+				if !levels.Has(level) {
+					return nil, fmt.Errorf("routing rule %s: invalid level %q", name, l)
+				}
+				r.levels[level] = true
+			}
+		}
+
+		if r.minLevel != "" && !levels.Has(r.minLevel) {
+			return nil, fmt.Errorf("routing rule %s: invalid min_level %q", name, r.minLevel)
+		}
+
+		if r.source != "" {
+			if _, err := path.Match(r.source, ""); err != nil {
+				return nil, fmt.Errorf("routing rule %s: invalid source glob %q: %w", name, r.source, err)
+			}
+		}
+
+		if rc.Match.SourceRegex != "" {
+			re, err := regexp.Compile(rc.Match.SourceRegex)
+			if err != nil {
+				return nil, fmt.Errorf("routing rule %s: invalid source_regex: %w", name, err)
+			}
+			r.sourceRegex = re
+		}
+
+		if rc.Match.TitleRegex != "" {
+			re, err := regexp.Compile(rc.Match.TitleRegex)
+			if err != nil {
+				return nil, fmt.Errorf("routing rule %s: invalid title_regex: %w", name, err)
+			}
+			r.titleRegex = re
+		}
+
+		if len(rc.Match.APIKeys) > 0 {
+			r.apiKeys = make(map[string]bool, len(rc.Match.APIKeys))
+			for _, k := range rc.Match.APIKeys {
+				r.apiKeys[k] = true
+			}
+		}
+
+		e.rules = append(e.rules, r)
+	}
+
+	return e, nil
+}
+
+// Resolve returns the de-duplicated channels for a request along with the
+// names of the rules that matched and the channels of matching digest rules.
+// Rules are evaluated in order; evaluation stops at the first match unless
+// the rule has continue set. The fallback channels are returned when no rule
+// matches.
+func (e *Engine) Resolve(req *notification.Request, apiKeyID string) (channels []notification.Channel, rules []string, digest []notification.Channel) {
+	var result []notification.Channel
+	var matched []string
+	seen := make(map[notification.Channel]bool)
+
+	for _, r := range e.rules {
+		if !r.matches(req, apiKeyID, e.levels) {
+			continue
+		}
+
+		matched = append(matched, r.name)
+		for _, ch := range r.channels {
+			if !seen[ch] {
+				seen[ch] = true
+				result = append(result, ch)
+			}
+		}
+		if r.digest {
+			digest = append(digest, r.channels...)
+		}
+
+		if !r.cont {
+			break
+		}
+	}
+
+	if len(matched) == 0 {
+		return append([]notification.Channel(nil), e.fallback...), nil, nil
+	}
+
+	return result, matched, digest
+}
+
+// matches reports whether the request satisfies every condition of the rule
+func (r *rule) matches(req *notification.Request, apiKeyID string, levels *notification.Levels) bool {
+	if r.levels != nil && !r.levels[req.Level] {
+		return false
+	}
+
+	if r.minLevel != "" && !levels.AtLeast(req.Level, r.minLevel) {
+		return false
+	}
+
+	if r.source != "" {
+		if ok, _ := path.Match(r.source, req.Source); !ok {
+			return false
+		}
+	}
+
+	if r.sourceRegex != nil && !r.sourceRegex.MatchString(req.Source) {
+		return false
+	}
+
+	if r.titleRegex != nil && !r.titleRegex.MatchString(req.Title) {
+		return false
+	}
+
+	for _, tag := range r.tags {
+		if !hasTag(req.Tags, tag) {
+			return false
+		}
+	}
+
+	if r.apiKeys != nil && !r.apiKeys[apiKeyID] {
+		return false
+	}
+
+	return true
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func toChannels(names []string) []notification.Channel {
+	channels := make([]notification.Channel, 0, len(names))
+	for _, name := range names {
+		channels = append(channels, notification.Channel(name))
+	}
+	return channels
+}
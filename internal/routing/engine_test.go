@@ -0,0 +1,175 @@
+package routing
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/luytbq/personal-notification-service/internal/config"
+	"github.com/luytbq/personal-notification-service/internal/notification"
+)
+
+func TestResolve(t *testing.T) {
+	groups, err := notification.NewGroups(map[string][]string{
+		"oncall": {"telegram", "webhook:pager"},
+	})
+	if err != nil {
+		t.Fatalf("NewGroups: %v", err)
+	}
+	cfg := config.RoutingConfig{
+		Rules: []config.RouteRule{
+			{
+				Name:     "critical",
+				Match:    config.RouteMatch{MinLevel: "critical"},
+				Channels: []string{"group:oncall"},
+				Continue: true,
+			},
+			{
+				Name:     "backups",
+				Match:    config.RouteMatch{Source: "backup-*", Levels: []string{"warning", "error", "critical"}},
+				Channels: []string{"telegram", "webhook:ops"},
+			},
+			{
+				Name:     "deploys",
+				Match:    config.RouteMatch{SourceRegex: "^deploy-(api|web)$", Tags: []string{"prod"}},
+				Channels: []string{"webhook:ops"},
+				Digest:   true,
+			},
+			{
+				Name:     "ci",
+				Match:    config.RouteMatch{TitleRegex: "(?i)build failed", APIKeys: []string{"ci"}},
+				Channels: []string{"telegram"},
+			},
+			{
+				Name:     "everything",
+				Channels: []string{"webhook:archive"},
+			},
+		},
+		Fallback: []string{"telegram"},
+	}
+	e, err := NewEngine(cfg, groups, notification.DefaultLevels)
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		req      notification.Request
+		apiKeyID string
+		channels []notification.Channel // after group expansion
+		rules    []string
+		digest   []notification.Channel
+	}{
+		{
+			name:     "first match stops",
+			req:      notification.Request{Level: notification.LevelError, Source: "backup-db"},
+			channels: []notification.Channel{"telegram", "webhook:ops"},
+			rules:    []string{"backups"},
+		},
+		{
+			name:     "continue adds later matches without duplicates",
+			req:      notification.Request{Level: notification.LevelCritical, Source: "backup-db"},
+			channels: []notification.Channel{"telegram", "webhook:pager", "webhook:ops"},
+			rules:    []string{"critical", "backups"},
+		},
+		{
+			name:     "continue falls through to the catch-all",
+			req:      notification.Request{Level: notification.LevelCritical, Source: "cron"},
+			channels: []notification.Channel{"telegram", "webhook:pager", "webhook:archive"},
+			rules:    []string{"critical", "everything"},
+		},
+		{
+			name:     "level outside the rule's levels",
+			req:      notification.Request{Level: notification.LevelInfo, Source: "backup-db"},
+			channels: []notification.Channel{"webhook:archive"},
+			rules:    []string{"everything"},
+		},
+		{
+			name:     "source regex and tags",
+			req:      notification.Request{Level: notification.LevelInfo, Source: "deploy-api", Tags: []string{"canary", "prod"}},
+			channels: []notification.Channel{"webhook:ops"},
+			rules:    []string{"deploys"},
+			digest:   []notification.Channel{"webhook:ops"},
+		},
+		{
+			name:     "missing tag",
+			req:      notification.Request{Level: notification.LevelInfo, Source: "deploy-api", Tags: []string{"canary"}},
+			channels: []notification.Channel{"webhook:archive"},
+			rules:    []string{"everything"},
+		},
+		{
+			name:     "title regex and API key",
+			req:      notification.Request{Title: "Build FAILED on main", Level: notification.LevelError},
+			apiKeyID: "ci",
+			channels: []notification.Channel{"telegram"},
+			rules:    []string{"ci"},
+		},
+		{
+			name:     "other API key",
+			req:      notification.Request{Title: "Build failed on main", Level: notification.LevelError},
+			apiKeyID: "ops",
+			channels: []notification.Channel{"webhook:archive"},
+			rules:    []string{"everything"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channels, rules, digest := e.Resolve(&tt.req, tt.apiKeyID)
+			if got := groups.Expand(channels); !slices.Equal(got, tt.channels) {
+				t.Errorf("channels = %v, want %v", got, tt.channels)
+			}
+			if !slices.Equal(rules, tt.rules) {
+				t.Errorf("rules = %v, want %v", rules, tt.rules)
+			}
+			if !slices.Equal(digest, tt.digest) {
+				t.Errorf("digest = %v, want %v", digest, tt.digest)
+			}
+		})
+	}
+}
+
+func TestResolveFallback(t *testing.T) {
+	groups, err := notification.NewGroups(map[string][]string{"oncall": {"telegram", "webhook:pager"}})
+	if err != nil {
+		t.Fatalf("NewGroups: %v", err)
+	}
+	cfg := config.RoutingConfig{
+		Rules:    []config.RouteRule{{Name: "backups", Match: config.RouteMatch{Source: "backup-*"}, Channels: []string{"telegram"}}},
+		Fallback: []string{"group:oncall", "telegram"},
+	}
+	e, err := NewEngine(cfg, groups, notification.DefaultLevels)
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	channels, rules, digest := e.Resolve(&notification.Request{Level: notification.LevelInfo, Source: "cron"}, "ops")
+	want := []notification.Channel{"telegram", "webhook:pager"}
+	if got := groups.Expand(channels); !slices.Equal(got, want) {
+		t.Errorf("channels = %v, want %v", got, want)
+	}
+	if rules != nil || digest != nil {
+		t.Errorf("rules = %v, digest = %v, want none", rules, digest)
+	}
+}
+
+func TestNewEngineErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule config.RouteRule
+	}{
+		{"no channels", config.RouteRule{}},
+		{"unknown group", config.RouteRule{Channels: []string{"group:nobody"}}},
+		{"invalid channel", config.RouteRule{Channels: []string{"pigeon"}}},
+		{"invalid level", config.RouteRule{Match: config.RouteMatch{Levels: []string{"loud"}}, Channels: []string{"telegram"}}},
+		{"invalid min level", config.RouteRule{Match: config.RouteMatch{MinLevel: "loud"}, Channels: []string{"telegram"}}},
+		{"invalid glob", config.RouteRule{Match: config.RouteMatch{Source: "backup-["}, Channels: []string{"telegram"}}},
+		{"invalid regex", config.RouteRule{Match: config.RouteMatch{TitleRegex: "("}, Channels: []string{"telegram"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.RoutingConfig{Rules: []config.RouteRule{tt.rule}}
+			if _, err := NewEngine(cfg, nil, notification.DefaultLevels); err == nil {
+				t.Error("NewEngine succeeded, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,335 @@
+package silence
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luytbq/personal-notification-service/internal/notification"
+	"github.com/redis/go-redis/v9"
+)
+
+// retention is how long a silence stays listed after it ends
+const retention = 24 * time.Hour
+
+// ErrNotFound is returned for an unknown silence ID
+var ErrNotFound = errors.New("silence not found")
+
+// Silence states, relative to the current time
+const (
+	StatePending = "pending" // starts in the future
+	StateActive  = "active"
+	StateExpired = "expired"
+)
+
+// Matchers select the notifications a silence applies to. Every non-empty
+// field must match, and at least one must be set.
+type Matchers struct {
+	Source     string                 `json:"source,omitempty"` // glob, e.g. "deploy-*"
+	Levels     []notification.Level   `json:"levels,omitempty"`
+	TitleRegex string                 `json:"title_regex,omitempty"`
+	Tags       []string               `json:"tags,omitempty"`     // notification must carry all listed tags
+	Channels   []notification.Channel `json:"channels,omitempty"` // concrete channels or targets
+}
+
+// Silence mutes matching notifications from StartsAt until EndsAt
+type Silence struct {
+	ID        string    `json:"id"`
+	Matchers  Matchers  `json:"matchers"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	CreatedBy string    `json:"created_by"`
+	Comment   string    `json:"comment"`
+	CreatedAt time.Time `json:"created_at"`
+
+	titleRegex *regexp.Regexp
+}
+
+// Validate checks a new silence. Levels must be known.
+func (s *Silence) Validate(levels *notification.Levels) error {
+	m := s.Matchers
+	switch {
+	case m.Source == "" && len(m.Levels) == 0 && m.TitleRegex == "" && len(m.Tags) == 0 && len(m.Channels) == 0:
+		return errors.New("at least one matcher is required")
+	case s.EndsAt.IsZero():
+		return errors.New("ends_at is required")
+	case !s.EndsAt.After(s.StartsAt):
+		return errors.New("ends_at must be after starts_at")
+	case !s.EndsAt.After(time.Now()):
+		return errors.New("ends_at must be in the future")
+	case s.CreatedBy == "":
+		return errors.New("created_by is required")
+	case s.Comment == "":
+		return errors.New("comment is required")
+	}
+
+	if m.Source != "" {
+		if _, err := path.Match(m.Source, ""); err != nil {
+			return fmt.Errorf("invalid source glob %q: %w", m.Source, err)
+		}
+	}
+	for _, l := range m.Levels {
+		if !levels.Has(l) {
+			return fmt.Errorf("invalid level %q", l)
+		}
+	}
+	for _, ch := range m.Channels {
+		if !ch.IsValid() {
+			return fmt.Errorf("invalid channel %q", ch)
+		}
+	}
+	return s.compile()
+}
+
+// compile prepares the title regex for matching
+func (s *Silence) compile() error {
+	if s.Matchers.TitleRegex == "" {
+		return nil
+	}
+	re, err := regexp.Compile(s.Matchers.TitleRegex)
+	if err != nil {
+		return fmt.Errorf("invalid title_regex: %w", err)
+	}
+	s.titleRegex = re
+	return nil
+}
+
+// State returns whether the silence is pending, active or expired at now
+func (s *Silence) State(now time.Time) string {
+	switch {
+	case now.Before(s.StartsAt):
+		return StatePending
+	case now.Before(s.EndsAt):
+		return StateActive
+	default:
+		return StateExpired
+	}
+}
+
+// Matches reports whether n satisfies every matcher of the silence
+func (s *Silence) Matches(n *notification.Notification) bool {
+	m := s.Matchers
+	if m.Source != "" {
+		if ok, _ := path.Match(m.Source, n.Source); !ok {
+			return false
+		}
+	}
+	if len(m.Levels) > 0 && !contains(m.Levels, n.Level) {
+		return false
+	}
+	if s.titleRegex != nil && !s.titleRegex.MatchString(n.Title) {
+		return false
+	}
+	for _, tag := range m.Tags {
+		if !contains(n.Tags, tag) {
+			return false
+		}
+	}
+	if len(m.Channels) > 0 && !contains(m.Channels, n.Channel) {
+		return false
+	}
+	return true
+}
+
+func contains[T comparable](list []T, v T) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheTTL is how long Match reuses the silences it read. Silences created or
+// expired on another replica take effect within this time.
+const cacheTTL = 5 * time.Second
+
+// Store keeps silences in a Redis hash indexed by ID, so that every replica
+// sees them
+type Store struct {
+	rdb    *redis.Client
+	key    string
+	logger *slog.Logger
+
+	mu       sync.Mutex
+	cached   []*Silence // silences that had not ended when read
+	cachedAt time.Time
+}
+
+// NewStore creates a new Redis-backed store using the given key prefix
+func NewStore(redisAddr, redisPassword string, redisDB int, prefix string, logger *slog.Logger) *Store {
+	return &Store{
+		rdb: redis.NewClient(&redis.Options{
+			Addr:     redisAddr,
+			Password: redisPassword,
+			DB:       redisDB,
+		}),
+		key:    fmt.Sprintf("%s:silences", prefix),
+		logger: logger,
+	}
+}
+
+// Close closes the Redis connection
+func (s *Store) Close() error {
+	return s.rdb.Close()
+}
+
+// Create stores a validated silence, assigning its ID and creation time
+func (s *Store) Create(ctx context.Context, sil *Silence) error {
+	sil.ID = uuid.New().String()
+	sil.CreatedAt = time.Now()
+	return s.save(ctx, sil)
+}
+
+// List returns all silences, most recently created first. Silences that
+// ended more than a day ago are deleted.
+func (s *Store) List(ctx context.Context) ([]*Silence, error) {
+	all, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-retention)
+	var stale []string
+	silences := make([]*Silence, 0, len(all))
+	for _, sil := range all {
+		if sil.EndsAt.Before(cutoff) {
+			stale = append(stale, sil.ID)
+			continue
+		}
+		silences = append(silences, sil)
+	}
+	if len(stale) > 0 {
+		if err := s.rdb.HDel(ctx, s.key, stale...).Err(); err != nil {
+			return nil, fmt.Errorf("failed to delete ended silences: %w", err)
+		}
+	}
+
+	sort.Slice(silences, func(i, j int) bool {
+		return silences[i].CreatedAt.After(silences[j].CreatedAt)
+	})
+	return silences, nil
+}
+
+// Expire ends a silence now. A silence that has already ended is returned
+// unchanged.
+func (s *Store) Expire(ctx context.Context, id string) (*Silence, error) {
+	raw, err := s.rdb.HGet(ctx, s.key, id).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get silence: %w", err)
+	}
+
+	var sil Silence
+	if err := json.Unmarshal([]byte(raw), &sil); err != nil {
+		return nil, fmt.Errorf("failed to decode silence %q: %w", id, err)
+	}
+
+	now := time.Now()
+	if sil.State(now) == StateExpired {
+		return &sil, nil
+	}
+	if sil.StartsAt.After(now) {
+		sil.StartsAt = now
+	}
+	sil.EndsAt = now
+	if err := s.save(ctx, &sil); err != nil {
+		return nil, err
+	}
+	return &sil, nil
+}
+
+// Match returns the first active silence that matches n, or nil. It is
+// called for every delivery, so it reads silences from a short-lived cache.
+func (s *Store) Match(ctx context.Context, n *notification.Notification) (*Silence, error) {
+	silences, err := s.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, sil := range silences {
+		if sil.State(now) == StateActive && sil.Matches(n) {
+			return sil, nil
+		}
+	}
+	return nil, nil
+}
+
+// current returns the silences that had not ended, reading them from Redis
+// at most once per cacheTTL
+func (s *Store) current(ctx context.Context) ([]*Silence, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if time.Since(s.cachedAt) < cacheTTL {
+		return s.cached, nil
+	}
+
+	all, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	s.cached = s.cached[:0:0]
+	for _, sil := range all {
+		if sil.EndsAt.After(now) {
+			s.cached = append(s.cached, sil)
+		}
+	}
+	s.cachedAt = now
+	return s.cached, nil
+}
+
+// load reads every silence, ready for matching. Entries that cannot be
+// decoded are logged and skipped, so that they do not disable the others.
+func (s *Store) load(ctx context.Context) ([]*Silence, error) {
+	values, err := s.rdb.HGetAll(ctx, s.key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read silences: %w", err)
+	}
+
+	silences := make([]*Silence, 0, len(values))
+	for id, raw := range values {
+		var sil Silence
+		err := json.Unmarshal([]byte(raw), &sil)
+		if err == nil {
+			err = sil.compile()
+		}
+		if err != nil {
+			s.logger.Warn("skipping unreadable silence",
+				slog.String("silence_id", id),
+				slog.String("error", err.Error()),
+			)
+			continue
+		}
+		silences = append(silences, &sil)
+	}
+	return silences, nil
+}
+
+// save writes a silence to the hash
+func (s *Store) save(ctx context.Context, sil *Silence) error {
+	data, err := json.Marshal(sil)
+	if err != nil {
+		return fmt.Errorf("failed to encode silence: %w", err)
+	}
+	if err := s.rdb.HSet(ctx, s.key, sil.ID, data).Err(); err != nil {
+		return fmt.Errorf("failed to store silence: %w", err)
+	}
+
+	// Take effect right away on this replica
+	s.mu.Lock()
+	s.cachedAt = time.Time{}
+	s.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,56 @@
+package stream
+
+import (
+	"time"
+
+	"github.com/luytbq/personal-notification-service/internal/history"
+	"github.com/luytbq/personal-notification-service/internal/notification"
+)
+
+// EventType distinguishes new notifications from delivery updates
+type EventType string
+
+const (
+	EventNotification EventType = "notification" // a notification was queued (or failed to queue)
+	EventStatus       EventType = "status"       // a delivery attempt changed the status
+)
+
+// Event is a notification or a change of its delivery status, as pushed to
+// stream clients
+type Event struct {
+	Type         EventType                 `json:"type"`
+	Status       history.Status            `json:"status"`
+	Error        string                    `json:"error,omitempty"`
+	Attempt      int                       `json:"attempt,omitempty"` // 1 for the first try; 0 before delivery
+	Timestamp    time.Time                 `json:"timestamp"`
+	Notification notification.Notification `json:"notification"`
+}
+
+// NewEvent creates an event for n
+func NewEvent(typ EventType, n *notification.Notification, status history.Status, errMsg string, attempt int) Event {
+	return Event{
+		Type:         typ,
+		Status:       status,
+		Error:        errMsg,
+		Attempt:      attempt,
+		Timestamp:    time.Now().UTC(),
+		Notification: *n,
+	}
+}
+
+// Filter selects the events a client receives. Empty fields match everything.
+type Filter struct {
+	Level    notification.Level
+	Source   string
+	Channel  notification.Channel
+	APIKeyID string
+}
+
+// Matches reports whether e passes the filter
+func (f Filter) Matches(e Event) bool {
+	n := e.Notification
+	return (f.Level == "" || n.Level == f.Level) &&
+		(f.Source == "" || n.Source == f.Source) &&
+		(f.Channel == "" || n.Channel == f.Channel) &&
+		(f.APIKeyID == "" || n.APIKeyID == f.APIKeyID)
+}
@@ -0,0 +1,159 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// subscriberBuffer is how many events a slow client may fall behind before
+// events are dropped for it
+const subscriberBuffer = 64
+
+// Hub carries events between replicas over Redis pub/sub. Every replica
+// publishes the events it produces and fans out everything it receives to
+// its local stream clients, so any replica can serve any client.
+type Hub struct {
+	rdb     *redis.Client
+	channel string
+	logger  *slog.Logger
+
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	closed bool // set once Run returns; new subscriptions end immediately
+}
+
+// NewHub creates a new hub using the given key prefix
+func NewHub(redisAddr, redisPassword string, redisDB int, prefix string, logger *slog.Logger) *Hub {
+	return &Hub{
+		rdb: redis.NewClient(&redis.Options{
+			Addr:     redisAddr,
+			Password: redisPassword,
+			DB:       redisDB,
+		}),
+		channel: fmt.Sprintf("%s:events:notifications", prefix),
+		logger:  logger,
+		subs:    make(map[*Subscription]struct{}),
+	}
+}
+
+// Close closes the Redis connection
+func (h *Hub) Close() error {
+	return h.rdb.Close()
+}
+
+// Publish sends an event to every replica. Streaming is best effort: a
+// failure is logged and does not affect delivery.
+func (h *Hub) Publish(ctx context.Context, e Event) {
+	data, err := json.Marshal(e)
+	if err == nil {
+		err = h.rdb.Publish(context.WithoutCancel(ctx), h.channel, data).Err()
+	}
+	if err != nil {
+		h.logger.Warn("failed to publish notification event",
+			slog.String("notification_id", e.Notification.ID),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// Run receives events from Redis and hands them to local subscribers, until
+// ctx is done. The subscription reconnects by itself after Redis errors.
+// When Run returns, every subscription's channel is closed so that open
+// streams end.
+func (h *Hub) Run(ctx context.Context) {
+	sub := h.rdb.Subscribe(ctx, h.channel)
+	defer sub.Close()
+	defer h.closeSubscriptions()
+
+	msgs := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-msgs:
+			if !ok {
+				return
+			}
+			var e Event
+			if err := json.Unmarshal([]byte(msg.Payload), &e); err != nil {
+				h.logger.Warn("failed to decode notification event", slog.String("error", err.Error()))
+				continue
+			}
+			h.dispatch(e)
+		}
+	}
+}
+
+// dispatch hands e to every matching subscriber without blocking on slow ones
+func (h *Hub) dispatch(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for s := range h.subs {
+		if !s.filter.Matches(e) {
+			continue
+		}
+		select {
+		case s.events <- e:
+		default:
+			h.logger.Warn("stream client too slow, event dropped",
+				slog.String("notification_id", e.Notification.ID),
+			)
+		}
+	}
+}
+
+// closeSubscriptions ends every subscription
+func (h *Hub) closeSubscriptions() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for s := range h.subs {
+		close(s.events)
+		delete(h.subs, s)
+	}
+}
+
+// Subscription receives the events matching its filter
+type Subscription struct {
+	hub    *Hub
+	filter Filter
+	events chan Event
+}
+
+// Subscribe registers a local subscriber. The caller must close it.
+func (h *Hub) Subscribe(filter Filter) *Subscription {
+	s := &Subscription{
+		hub:    h,
+		filter: filter,
+		events: make(chan Event, subscriberBuffer),
+	}
+
+	h.mu.Lock()
+	if h.closed {
+		close(s.events)
+	} else {
+		h.subs[s] = struct{}{}
+	}
+	h.mu.Unlock()
+	return s
+}
+
+// Events returns the channel events are delivered on. It is closed when the
+// hub shuts down.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close unregisters the subscriber
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	delete(s.hub.subs, s)
+	s.hub.mu.Unlock()
+}
@@ -0,0 +1,181 @@
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/luytbq/personal-notification-service/internal/config"
+	"github.com/luytbq/personal-notification-service/internal/notification"
+)
+
+var (
+	ErrUnknownTemplate  = errors.New("unknown template")
+	ErrMissingVars      = errors.New("missing template variables")
+	ErrContentConflicts = errors.New("title and message must not be set when using a template")
+	ErrRender           = errors.New("failed to render template")
+)
+
+// content is a compiled title and message; nil parts are inherited
+type content struct {
+	title   *template.Template
+	message *template.Template
+}
+
+// tmpl is a compiled message template
+type tmpl struct {
+	content   content
+	level     notification.Level
+	channels  []notification.Channel
+	required  []string
+	overrides map[notification.Channel]content
+}
+
+// Set holds the configured templates by name
+type Set struct {
+	templates map[string]*tmpl
+}
+
+// NewSet compiles the configured templates. Default channels may be concrete
+// channels or one of the given groups; overrides name concrete channels.
+func NewSet(defs map[string]config.TemplateConfig, groups notification.Groups, levels *notification.Levels) (*Set, error) {
+	s := &Set{templates: make(map[string]*tmpl, len(defs))}
+
+	for name, def := range defs {
+		if name == "" {
+			return nil, errors.New("template name must not be empty")
+		}
+		if def.Title == "" || def.Message == "" {
+			return nil, fmt.Errorf("template %s: title and message are required", name)
+		}
+
+		t := &tmpl{
+			level:     notification.Level(def.Level),
+			required:  def.Required,
+			overrides: make(map[notification.Channel]content, len(def.Overrides)),
+		}
+
+		var err error
+		if t.content, err = compile(name, def.TemplateContent); err != nil {
+			return nil, err
+		}
+
+		if t.level != "" && !levels.Has(t.level) {
+			return nil, fmt.Errorf("template %s: invalid level %q", name, def.Level)
+		}
+
+		for _, c := range def.Channels {
+			ch := notification.Channel(c)
+			if !ch.IsValid() && !groups.Has(ch) {
+				return nil, fmt.Errorf("template %s: invalid channel %q", name, c)
+			}
+			t.channels = append(t.channels, ch)
+		}
+
+		for c, override := range def.Overrides {
+			ch := notification.Channel(c)
+			if !ch.IsValid() {
+				return nil, fmt.Errorf("template %s: invalid override channel %q", name, c)
+			}
+			if t.overrides[ch], err = compile(name+"@"+c, override); err != nil {
+				return nil, err
+			}
+		}
+
+		s.templates[name] = t
+	}
+
+	return s, nil
+}
+
+// compile parses a title and message. Referencing a variable the request did
+// not provide is an error rather than rendering "<no value>".
+func compile(name string, c config.TemplateContent) (content, error) {
+	var (
+		out content
+		err error
+	)
+	if c.Title != "" {
+		out.title, err = template.New(name + ".title").Option("missingkey=error").Parse(c.Title)
+		if err != nil {
+			return out, fmt.Errorf("template %s: invalid title: %w", name, err)
+		}
+	}
+	if c.Message != "" {
+		out.message, err = template.New(name + ".message").Option("missingkey=error").Parse(c.Message)
+		if err != nil {
+			return out, fmt.Errorf("template %s: invalid message: %w", name, err)
+		}
+	}
+	return out, nil
+}
+
+// Apply renders the template named by req.Template into req. The template's
+// level and channels are used when the request does not set them, and
+// per-channel overrides are stored in req.ChannelContent.
+func (s *Set) Apply(req *notification.Request) error {
+	t, ok := s.templates[req.Template]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownTemplate, req.Template)
+	}
+	if req.Title != "" || req.Message != "" {
+		return ErrContentConflicts
+	}
+
+	var missing []string
+	for _, name := range t.required {
+		if _, ok := req.Vars[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", ErrMissingVars, strings.Join(missing, ", "))
+	}
+
+	var err error
+	if req.Title, err = render(t.content.title, req.Vars); err != nil {
+		return err
+	}
+	if req.Message, err = render(t.content.message, req.Vars); err != nil {
+		return err
+	}
+
+	if len(t.overrides) > 0 {
+		req.ChannelContent = make(map[notification.Channel]notification.Content, len(t.overrides))
+		for ch, override := range t.overrides {
+			c := notification.Content{Title: req.Title, Message: req.Message}
+			if override.title != nil {
+				if c.Title, err = render(override.title, req.Vars); err != nil {
+					return err
+				}
+			}
+			if override.message != nil {
+				if c.Message, err = render(override.message, req.Vars); err != nil {
+					return err
+				}
+			}
+			req.ChannelContent[ch] = c
+		}
+	}
+
+	if req.Level == "" {
+		req.Level = t.level
+	}
+	if len(req.Channels) == 0 {
+		req.Channels = append([]notification.Channel(nil), t.channels...)
+	}
+	return nil
+}
+
+// render executes t with vars
+func render(t *template.Template, vars map[string]any) (string, error) {
+	if vars == nil {
+		vars = map[string]any{}
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, vars); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrRender, err)
+	}
+	return b.String(), nil
+}